@@ -0,0 +1,104 @@
+package simplesessions
+
+import "time"
+
+// EventType is the type of a session lifecycle event.
+type EventType int
+
+const (
+	// EventCreate is emitted when a new session is created by `NewSession()`
+	// or auto-created by `Acquire()`.
+	EventCreate EventType = iota + 1
+
+	// EventAcquire is emitted when an existing session is acquired from the cookie.
+	EventAcquire
+
+	// EventExpire is emitted when `Acquire()` finds that a session has exceeded
+	// the idle or absolute timeout and destroys it.
+	EventExpire
+
+	// EventRegenerate is emitted when the session ID is rotated with `Regenerate()`.
+	EventRegenerate
+
+	// EventClear is emitted when the session is emptied with `Clear()`.
+	EventClear
+
+	// EventDestroy is emitted when the session is destroyed with `Destroy()`, or
+	// by `Manager.RevokeUserSessions()` or `Options.MaxSessionsPerUser` evictions,
+	// in which case there's no request.
+	EventDestroy
+
+	// EventWriteCookie is emitted when the session cookie is written or cleared.
+	EventWriteCookie
+)
+
+var eventNames = map[EventType]string{
+	EventCreate:      "create",
+	EventAcquire:     "acquire",
+	EventExpire:      "expire",
+	EventRegenerate:  "regenerate",
+	EventClear:       "clear",
+	EventDestroy:     "destroy",
+	EventWriteCookie: "write_cookie",
+}
+
+// String returns the name of the event type, eg: "create".
+func (t EventType) String() string {
+	if n, ok := eventNames[t]; ok {
+		return n
+	}
+	return "unknown"
+}
+
+// Event is a session lifecycle event passed to the hooks registered with `Manager.OnEvent()`.
+type Event struct {
+	Type EventType
+
+	// ID of the session. For EventRegenerate, it's the new ID.
+	ID string
+
+	// PrevID is the ID of the session before it was regenerated. Only set for EventRegenerate.
+	PrevID string
+
+	// Request and response interfaces passed to `Acquire()` or `NewSession()`,
+	// eg: *http.Request and http.ResponseWriter. nil if there's no request.
+	Reader interface{}
+	Writer interface{}
+
+	// Time is when the operation that emitted the event started
+	// and Duration is how long it took, including the store calls.
+	Time     time.Time
+	Duration time.Duration
+}
+
+// OnEvent registers a hook that's called synchronously on session lifecycle events,
+// eg: for auditing. Hooks are called in the order they're registered and should
+// be registered before the Manager is used. Hooks shouldn't block as they're
+// called on the request path.
+func (m *Manager) OnEvent(fn func(Event)) {
+	m.hooks = append(m.hooks, fn)
+}
+
+// emit calls the registered hooks with the event. Duration is set to the time
+// elapsed since the event's Time.
+func (m *Manager) emit(e Event) {
+	if len(m.hooks) == 0 {
+		return
+	}
+
+	e.Duration = time.Since(e.Time)
+	for _, fn := range m.hooks {
+		fn(e)
+	}
+}
+
+// emit emits an event of the given type for the session that started at the given time.
+func (s *Session) emit(t EventType, start time.Time) {
+	s.manager.emit(Event{
+		Type:   t,
+		ID:     s.id,
+		Reader: s.reader,
+		Writer: s.writer,
+		Time:   start,
+	})
+}
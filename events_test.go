@@ -0,0 +1,127 @@
+package simplesessions
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventType(t *testing.T) {
+	assert.Equal(t, "create", EventCreate.String())
+	assert.Equal(t, "write_cookie", EventWriteCookie.String())
+	assert.Equal(t, "unknown", EventType(0).String())
+}
+
+func TestOnEvent(t *testing.T) {
+	var (
+		str    = &mockRenameStore{MockStore: newMockStore()}
+		m      = New(Options{})
+		req    = &http.Request{}
+		events []Event
+		order  []int
+	)
+	m.UseStore(str)
+	m.SetCookieHooks(mockGetCookieCb, mockSetCookieCb)
+
+	// Hooks are called in the order they're registered.
+	m.OnEvent(func(e Event) {
+		events = append(events, e)
+		order = append(order, 1)
+	})
+	m.OnEvent(func(e Event) {
+		order = append(order, 2)
+	})
+
+	// types returns the types of the events emitted so far and resets them.
+	types := func() []EventType {
+		out := make([]EventType, len(events))
+		for i, e := range events {
+			out[i] = e.Type
+		}
+		events = nil
+		return out
+	}
+
+	start := time.Now()
+	sess, err := m.NewSession(req, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 1, 2}, order)
+
+	e := events[1]
+	assert.Equal(t, sess.ID(), e.ID)
+	assert.Equal(t, req, e.Reader)
+	assert.False(t, e.Time.Before(start))
+	assert.GreaterOrEqual(t, e.Duration, time.Duration(0))
+	assert.Equal(t, []EventType{EventWriteCookie, EventCreate}, types())
+
+	sess, err = m.Acquire(context.Background(), req, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []EventType{EventAcquire}, types())
+
+	// Sessions from the context aren't acquired again.
+	_, err = m.Acquire(context.WithValue(context.Background(), ContextName, sess), req, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, types())
+
+	assert.NoError(t, sess.Regenerate())
+	assert.Equal(t, EventRegenerate, events[1].Type)
+	assert.Equal(t, sess.ID(), events[1].ID)
+	assert.Equal(t, mockSessionID, events[1].PrevID)
+	assert.Equal(t, []EventType{EventWriteCookie, EventRegenerate}, types())
+
+	assert.NoError(t, sess.Clear())
+	assert.Equal(t, []EventType{EventClear}, types())
+
+	assert.NoError(t, sess.Destroy())
+	assert.Equal(t, []EventType{EventDestroy, EventWriteCookie}, types())
+
+	// Destroying a session that doesn't exist only clears the cookie.
+	assert.NoError(t, sess.Destroy())
+	assert.Equal(t, []EventType{EventWriteCookie}, types())
+
+	// Failed operations don't emit events.
+	str.data = map[string]interface{}{}
+	str.err = errors.New("store error")
+	assert.Error(t, sess.Destroy())
+	assert.Empty(t, types())
+}
+
+func TestOnEventExpire(t *testing.T) {
+	var (
+		str    = newMockStore()
+		m      = newMockManager(str)
+		events []EventType
+	)
+	m.opts.IdleTimeout = time.Minute
+	m.OnEvent(func(e Event) {
+		events = append(events, e.Type)
+	})
+
+	str.data[keyLastSeen] = time.Now().Add(-time.Minute * 2).Unix()
+	_, err := m.Acquire(context.Background(), nil, nil)
+	assert.ErrorIs(t, err, ErrInvalidSession)
+	assert.Equal(t, []EventType{EventExpire}, events)
+}
+
+func TestOnEventRevoke(t *testing.T) {
+	var (
+		str    = newMockUserIndexStore()
+		m      = New(Options{})
+		events []Event
+	)
+	m.UseStore(str)
+	m.OnEvent(func(e Event) {
+		events = append(events, e)
+	})
+
+	str.own("id1", "user1")
+	assert.NoError(t, m.RevokeUserSessions("user1"))
+	assert.Len(t, events, 1)
+	assert.Equal(t, EventDestroy, events[0].Type)
+	assert.Equal(t, "id1", events[0].ID)
+	assert.Nil(t, events[0].Reader)
+}
@@ -2,13 +2,14 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
-	"net/http"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/valyala/fasthttp"
 	"github.com/zerodha/fastglue"
+	"github.com/zerodha/simplesessions/fasthttpadapter/v3"
 	redisstore "github.com/zerodha/simplesessions/stores/redis/v3"
 	"github.com/zerodha/simplesessions/v3"
 )
@@ -45,7 +46,7 @@ func initServer(name string, timeout int) *fasthttp.Server {
 func setHandler(r *fastglue.Request) error {
 	sess, err := sessMgr.Acquire(nil, r.RequestCtx, r.RequestCtx)
 	// Create new session if it doesn't exist.
-	if err == simplesessions.ErrInvalidSession {
+	if errors.Is(err, simplesessions.ErrInvalidSession) {
 		sess, err = sessMgr.NewSession(r.RequestCtx, r.RequestCtx)
 	}
 	if err != nil {
@@ -87,53 +88,6 @@ func destroyHandler(r *fastglue.Request) error {
 	return r.SendEnvelope(true)
 }
 
-// getCookie() gets the fasthttp cookie and passes its values to a http.Cookie
-func getCookie(name string, r interface{}) (*http.Cookie, error) {
-	ctx := r.(*fasthttp.RequestCtx)
-	cookieBody := ctx.Request.Header.Cookie(name)
-	// If cookie if empty then send no cookie error
-	if len(cookieBody) == 0 {
-		return nil, http.ErrNoCookie
-	}
-
-	// Create fast http cookie and parse it from cookie bytes
-	var cookie fasthttp.Cookie
-	if err := cookie.ParseBytes(cookieBody); err != nil {
-		return nil, err
-	}
-
-	// Convert fasthttp cookie to net http cookie since
-	// simple sessions support cookies in net http format
-	return &http.Cookie{
-		Name:     name,
-		Value:    string(cookie.Value()),
-		Path:     string(cookie.Path()),
-		Domain:   string(cookie.Domain()),
-		Expires:  cookie.Expire(),
-		Secure:   cookie.Secure(),
-		HttpOnly: cookie.HTTPOnly(),
-	}, nil
-}
-
-// setCookie() sets a fasthttp.Cookie by passing http.Cookie values
-func setCookie(cookie *http.Cookie, w interface{}) error {
-	ctx := w.(*fasthttp.RequestCtx)
-
-	// Acquire cookie
-	fck := fasthttp.AcquireCookie()
-	defer fasthttp.ReleaseCookie(fck)
-	fck.SetKey(cookie.Name)
-	fck.SetValue(cookie.Value)
-	fck.SetPath(cookie.Path)
-	fck.SetDomain(cookie.Domain)
-	fck.SetExpire(cookie.Expires)
-	fck.SetSecure(cookie.Secure)
-	fck.SetHTTPOnly(cookie.HttpOnly)
-
-	ctx.Response.Header.SetCookie(fck)
-	return nil
-}
-
 func main() {
 
 	rc := initRedisGo("localhost:6379", "")
@@ -142,7 +96,7 @@ func main() {
 
 	sessMgr = simplesessions.New(simplesessions.Options{})
 	sessMgr.UseStore(store)
-	sessMgr.SetCookieHooks(getCookie, setCookie)
+	sessMgr.SetCookieHooks(fasthttpadapter.GetCookie, fasthttpadapter.SetCookie)
 
 	g := fastglue.New()
 	g.GET("/get", getHandler)
@@ -2,13 +2,14 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"net/http"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/valyala/fasthttp"
+	"github.com/zerodha/simplesessions/fasthttpadapter/v3"
 	redisstore "github.com/zerodha/simplesessions/stores/redis/v3"
 	"github.com/zerodha/simplesessions/v3"
 )
@@ -23,7 +24,7 @@ var (
 func setHandler(ctx *fasthttp.RequestCtx) {
 	sess, err := sessMgr.Acquire(nil, ctx, ctx)
 	// Create new session if it doesn't exist.
-	if err == simplesessions.ErrInvalidSession {
+	if errors.Is(err, simplesessions.ErrInvalidSession) {
 		sess, err = sessMgr.NewSession(ctx, ctx)
 	}
 
@@ -57,51 +58,6 @@ func getHandler(ctx *fasthttp.RequestCtx) {
 	fmt.Fprintf(ctx, "success: %v", val == testValue)
 }
 
-func getCookie(name string, r interface{}) (*http.Cookie, error) {
-	ctx := r.(*fasthttp.RequestCtx)
-	cBytes := ctx.Request.Header.Cookie(name)
-	// If cookie if empty then send no cookie error
-	if len(cBytes) == 0 {
-		return nil, http.ErrNoCookie
-	}
-
-	// Create fast http cookie and parse it from cookie bytes
-	var cookie fasthttp.Cookie
-	if err := cookie.ParseBytes(cBytes); err != nil {
-		return nil, err
-	}
-
-	// Convert fasthttp cookie to net http cookie since
-	// simple sessions support cookies in net http format
-	return &http.Cookie{
-		Name:     name,
-		Value:    string(cookie.Value()),
-		Path:     string(cookie.Path()),
-		Domain:   string(cookie.Domain()),
-		Expires:  cookie.Expire(),
-		Secure:   cookie.Secure(),
-		HttpOnly: cookie.HTTPOnly(),
-	}, nil
-}
-
-func setCookie(cookie *http.Cookie, w interface{}) error {
-	ctx := w.(*fasthttp.RequestCtx)
-
-	// Acquire cookie
-	fck := fasthttp.AcquireCookie()
-	defer fasthttp.ReleaseCookie(fck)
-	fck.SetKey(cookie.Name)
-	fck.SetValue(cookie.Value)
-	fck.SetPath(cookie.Path)
-	fck.SetDomain(cookie.Domain)
-	fck.SetExpire(cookie.Expires)
-	fck.SetSecure(cookie.Secure)
-	fck.SetHTTPOnly(cookie.HttpOnly)
-
-	ctx.Response.Header.SetCookie(fck)
-	return nil
-}
-
 func getRedisPool() redis.UniversalClient {
 	o := &redis.Options{
 		Addr:        "localhost:6379",
@@ -128,7 +84,7 @@ func main() {
 	sessMgr = simplesessions.New(simplesessions.Options{})
 	store := redisstore.New(context.TODO(), rPool)
 	sessMgr.UseStore(store)
-	sessMgr.SetCookieHooks(getCookie, setCookie)
+	sessMgr.SetCookieHooks(fasthttpadapter.GetCookie, fasthttpadapter.SetCookie)
 
 	m := func(ctx *fasthttp.RequestCtx) {
 		switch string(ctx.Path()) {
@@ -7,6 +7,7 @@ import (
 
 	"github.com/zerodha/simplesessions/stores/memory/v3"
 	"github.com/zerodha/simplesessions/v3"
+	"github.com/zerodha/simplesessions/v3/nethttp"
 )
 
 var (
@@ -16,19 +17,20 @@ var (
 )
 
 func setHandler(w http.ResponseWriter, r *http.Request) {
-	sess, err := sessMgr.Acquire(nil, r, w)
+	// Session acquired by the middleware.
+	sess := nethttp.FromContext(r.Context())
 
 	// Create new session if it doesn't exist.
-	if err == simplesessions.ErrInvalidSession {
-		sess, err = sessMgr.NewSession(r, w)
+	if sess == nil {
+		s, err := sessMgr.NewSession(r, w)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		sess = s
 	}
 
-	if err != nil {
-		http.Error(w, err.Error(), 500)
-		return
-	}
-
-	err = sess.Set(testKey, testValue)
+	err := sess.Set(testKey, testValue)
 	if err != nil {
 		http.Error(w, err.Error(), 500)
 		return
@@ -38,9 +40,9 @@ func setHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func getHandler(w http.ResponseWriter, r *http.Request) {
-	sess, err := sessMgr.Acquire(nil, r, w)
-	if err != nil {
-		http.Error(w, err.Error(), 500)
+	sess := nethttp.FromContext(r.Context())
+	if sess == nil {
+		http.Error(w, simplesessions.ErrInvalidSession.Error(), 500)
 		return
 	}
 
@@ -53,28 +55,13 @@ func getHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "success: %v", val == testValue)
 }
 
-func getCookie(name string, r interface{}) (*http.Cookie, error) {
-	rd := r.(*http.Request)
-	cookie, err := rd.Cookie(name)
-	if err != nil {
-		return nil, err
-	}
-
-	return cookie, nil
-}
-
-func setCookie(cookie *http.Cookie, w interface{}) error {
-	wr := w.(http.ResponseWriter)
-	http.SetCookie(wr, cookie)
-	return nil
-}
-
 func main() {
 	sessMgr = simplesessions.New(simplesessions.Options{})
 	sessMgr.UseStore(memory.New())
-	sessMgr.SetCookieHooks(getCookie, setCookie)
+	sessMgr.SetCookieHooks(nethttp.GetCookie, nethttp.SetCookie)
 
-	http.HandleFunc("/set", setHandler)
-	http.HandleFunc("/get", getHandler)
-	log.Fatal(http.ListenAndServe(":1111", nil))
+	mux := http.NewServeMux()
+	mux.HandleFunc("/set", setHandler)
+	mux.HandleFunc("/get", getHandler)
+	log.Fatal(http.ListenAndServe(":1111", nethttp.Middleware(sessMgr)(mux)))
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -23,7 +24,7 @@ func setHandler(w http.ResponseWriter, r *http.Request) {
 	sess, err := sessMgr.Acquire(nil, r, w)
 
 	// Create new session if it doesn't exist.
-	if err == simplesessions.ErrInvalidSession {
+	if errors.Is(err, simplesessions.ErrInvalidSession) {
 		sess, err = sessMgr.NewSession(r, w)
 	}
 
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -24,17 +25,8 @@ var (
 func setHandler(w http.ResponseWriter, r *http.Request) {
 	sess, err := sessMgr.Acquire(nil, r, w)
 	// Create new session if it doesn't exist.
-	if err == simplesessions.ErrInvalidSession {
+	if errors.Is(err, simplesessions.ErrInvalidSession) {
 		sess, err = sessMgr.NewSession(r, w)
-
-		// IMPORTANT: any Set/SetMulti/Delete/Clear/Destroy and NewSession()
-		// should flush the values using `store.Flush()` otherwise cookie won't be updated.
-		if err == nil {
-			ck, err := store.Flush(sess.ID())
-			if err == nil {
-				err = sess.WriteCookie(ck)
-			}
-		}
 	}
 
 	if err != nil {
@@ -42,26 +34,14 @@ func setHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The session data lives in the cookie. The store is flushed and
+	// the cookie is rewritten automatically after every write.
 	err = sess.Set(testKey, testValue)
 	if err != nil {
 		http.Error(w, err.Error(), 500)
 		return
 	}
 
-	// For securecookies, ID() of the session is the encoded cookie
-	// data itself.
-	v, err := store.Flush(sess.ID())
-	if err != nil {
-		http.Error(w, err.Error(), 500)
-		return
-	}
-
-	// Write the cookie.
-	if err := sess.WriteCookie(v); err != nil {
-		http.Error(w, err.Error(), 500)
-		return
-	}
-
 	fmt.Fprintf(w, "success")
 }
 
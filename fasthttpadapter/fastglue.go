@@ -0,0 +1,38 @@
+package fasthttpadapter
+
+import (
+	"github.com/zerodha/fastglue"
+	"github.com/zerodha/simplesessions/v3"
+)
+
+// Before returns a fastglue middleware that acquires the session for every request
+// and stores it in the request's user values, like `Handler()`. Register it using
+// `fastglue.Before()` and use `FromRequestCtx(r.RequestCtx)` to retrieve the session.
+func Before(m *simplesessions.Manager) fastglue.FastMiddleware {
+	return func(r *fastglue.Request) *fastglue.Request {
+		if _, ok := acquire(m, r.RequestCtx); !ok {
+			return nil
+		}
+
+		return r
+	}
+}
+
+// After returns a fastglue middleware that commits the buffered writes of the session
+// acquired by `Before()` and releases it once the handler has run. If the commit fails,
+// the response is replaced with a 500. Register it using `fastglue.After()`.
+func After() fastglue.FastMiddleware {
+	return func(r *fastglue.Request) *fastglue.Request {
+		sess := FromRequestCtx(r.RequestCtx)
+		if sess == nil {
+			return r
+		}
+		r.RequestCtx.RemoveUserValue(simplesessions.ContextName)
+
+		if !commit(sess, r.RequestCtx) {
+			return nil
+		}
+
+		return r
+	}
+}
@@ -0,0 +1,157 @@
+// Package fasthttpadapter provides ready-made cookie hooks, a request handler
+// wrapper and fastglue middlewares for using simplesessions with fasthttp.
+package fasthttpadapter
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/simplesessions/v3"
+)
+
+var (
+	// ErrInvalidReader is returned by GetCookie if the reader isn't *fasthttp.RequestCtx.
+	ErrInvalidReader = errors.New("fasthttpadapter: reader is not *fasthttp.RequestCtx")
+
+	// ErrInvalidWriter is returned by SetCookie if the writer isn't *fasthttp.RequestCtx.
+	ErrInvalidWriter = errors.New("fasthttpadapter: writer is not *fasthttp.RequestCtx")
+)
+
+// GetCookie is a get cookie hook for `Manager.SetCookieHooks()`.
+// r should be the *fasthttp.RequestCtx passed to `Manager.Acquire()`.
+// http.ErrNoCookie is returned if the cookie doesn't exist.
+func GetCookie(name string, r interface{}) (*http.Cookie, error) {
+	ctx, ok := r.(*fasthttp.RequestCtx)
+	if !ok {
+		return nil, ErrInvalidReader
+	}
+
+	// Request cookies only carry the value.
+	val := ctx.Request.Header.Cookie(name)
+	if len(val) == 0 {
+		return nil, http.ErrNoCookie
+	}
+
+	return &http.Cookie{
+		Name:  name,
+		Value: string(val),
+	}, nil
+}
+
+// SetCookie is a set cookie hook for `Manager.SetCookieHooks()`.
+// w should be the *fasthttp.RequestCtx passed to `Manager.Acquire()`.
+// All the cookie attributes including SameSite and MaxAge are retained.
+func SetCookie(cookie *http.Cookie, w interface{}) error {
+	ctx, ok := w.(*fasthttp.RequestCtx)
+	if !ok {
+		return ErrInvalidWriter
+	}
+
+	ck := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(ck)
+
+	ck.SetKey(cookie.Name)
+	ck.SetValue(cookie.Value)
+	ck.SetPath(cookie.Path)
+	ck.SetDomain(cookie.Domain)
+	ck.SetSecure(cookie.Secure)
+	ck.SetHTTPOnly(cookie.HttpOnly)
+	ck.SetSameSite(sameSite(cookie.SameSite))
+
+	if !cookie.Expires.IsZero() {
+		ck.SetExpire(cookie.Expires)
+	}
+
+	// MaxAge < 0 in net/http means delete the cookie now.
+	if cookie.MaxAge > 0 {
+		ck.SetMaxAge(cookie.MaxAge)
+	} else if cookie.MaxAge < 0 {
+		ck.SetExpire(fasthttp.CookieExpireDelete)
+	}
+
+	ctx.Response.Header.SetCookie(ck)
+	return nil
+}
+
+// Handler wraps a fasthttp request handler. It acquires the session for every request
+// and stores it in the request's user values under `simplesessions.ContextName`.
+// As *fasthttp.RequestCtx is a context.Context that returns user values,
+// subsequent `Manager.Acquire()` calls with the RequestCtx return the same session.
+// Use `FromRequestCtx()` to retrieve it in handlers.
+//
+// If there's no session and `Options.EnableAutoCreate` is disabled, the request
+// is passed on without a session. Any other error responds with 500.
+//
+// If `Options.EnableBufferedWrites` is set, the session is committed after the
+// handler returns. If the commit fails, the response is replaced with a 500.
+func Handler(m *simplesessions.Manager, h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		sess, ok := acquire(m, ctx)
+		if !ok {
+			return
+		}
+
+		h(ctx)
+
+		if sess != nil {
+			commit(sess, ctx)
+		}
+	}
+}
+
+// FromRequestCtx returns the session stored in the request's user values by
+// `Handler()` or `Before()`. nil is returned if there's no session.
+func FromRequestCtx(ctx *fasthttp.RequestCtx) *simplesessions.Session {
+	sess, _ := ctx.UserValue(simplesessions.ContextName).(*simplesessions.Session)
+	return sess
+}
+
+// acquire acquires the session and stores it in the request's user values.
+// It returns false if the request has been responded to with an error.
+func acquire(m *simplesessions.Manager, ctx *fasthttp.RequestCtx) (*simplesessions.Session, bool) {
+	sess, err := m.Acquire(ctx, ctx, ctx)
+	if err != nil {
+		if errors.Is(err, simplesessions.ErrInvalidSession) {
+			return nil, true
+		}
+
+		internalError(ctx)
+		return nil, false
+	}
+
+	ctx.SetUserValue(simplesessions.ContextName, sess)
+	return sess, true
+}
+
+// commit commits the session's buffered writes. As fasthttp sends the response
+// only after the handler returns, the response is replaced with a 500 on error.
+// It returns false if the commit failed.
+func commit(sess *simplesessions.Session, ctx *fasthttp.RequestCtx) bool {
+	if err := sess.Commit(); err != nil {
+		internalError(ctx)
+		return false
+	}
+
+	return true
+}
+
+func internalError(ctx *fasthttp.RequestCtx) {
+	ctx.Error(fasthttp.StatusMessage(fasthttp.StatusInternalServerError), fasthttp.StatusInternalServerError)
+}
+
+// sameSite converts net/http SameSite mode to fasthttp's.
+func sameSite(s http.SameSite) fasthttp.CookieSameSite {
+	switch s {
+	case http.SameSiteDefaultMode:
+		return fasthttp.CookieSameSiteDefaultMode
+	case http.SameSiteLaxMode:
+		return fasthttp.CookieSameSiteLaxMode
+	case http.SameSiteStrictMode:
+		return fasthttp.CookieSameSiteStrictMode
+	case http.SameSiteNoneMode:
+		return fasthttp.CookieSameSiteNoneMode
+	}
+
+	return fasthttp.CookieSameSiteDisabled
+}
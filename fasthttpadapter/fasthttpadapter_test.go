@@ -0,0 +1,223 @@
+package fasthttpadapter
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/simplesessions/v3"
+)
+
+// mockStore is a minimal map based store for testing.
+type mockStore struct {
+	simplesessions.Store
+	mu       sync.Mutex
+	sessions map[string]map[string]interface{}
+	err      error
+}
+
+func newMockStore() *mockStore {
+	return &mockStore{sessions: map[string]map[string]interface{}{}}
+}
+
+func (s *mockStore) Create(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[id] = map[string]interface{}{}
+	return nil
+}
+
+func (s *mockStore) Set(id, key string, val interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return simplesessions.ErrInvalidSession
+	}
+	sess[key] = val
+	return nil
+}
+
+func (s *mockStore) SetMulti(id string, data map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	sess, ok := s.sessions[id]
+	if !ok {
+		return simplesessions.ErrInvalidSession
+	}
+	for k, v := range data {
+		sess[k] = v
+	}
+	return nil
+}
+
+func newManager(str simplesessions.Store, autoCreate bool) *simplesessions.Manager {
+	m := simplesessions.New(simplesessions.Options{EnableAutoCreate: autoCreate})
+	m.UseStore(str)
+	m.SetCookieHooks(GetCookie, SetCookie)
+	return m
+}
+
+func TestGetCookie(t *testing.T) {
+	_, err := GetCookie("session", nil)
+	assert.ErrorIs(t, err, ErrInvalidReader)
+
+	var ctx fasthttp.RequestCtx
+	_, err = GetCookie("session", &ctx)
+	assert.ErrorIs(t, err, http.ErrNoCookie)
+
+	ctx.Request.Header.SetCookie("session", "abc")
+	ck, err := GetCookie("session", &ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "session", ck.Name)
+	assert.Equal(t, "abc", ck.Value)
+}
+
+func TestSetCookie(t *testing.T) {
+	assert.ErrorIs(t, SetCookie(&http.Cookie{}, nil), ErrInvalidWriter)
+
+	var ctx fasthttp.RequestCtx
+	assert.NoError(t, SetCookie(&http.Cookie{
+		Name:     "session",
+		Value:    "xyz",
+		Path:     "/",
+		Domain:   "example.com",
+		MaxAge:   3600,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	}, &ctx))
+
+	ck := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(ck)
+	ck.SetKey("session")
+	assert.True(t, ctx.Response.Header.Cookie(ck))
+	assert.Equal(t, "xyz", string(ck.Value()))
+	assert.Equal(t, "/", string(ck.Path()))
+	assert.Equal(t, "example.com", string(ck.Domain()))
+	assert.Equal(t, 3600, ck.MaxAge())
+	assert.True(t, ck.Secure())
+	assert.True(t, ck.HTTPOnly())
+	assert.Equal(t, fasthttp.CookieSameSiteStrictMode, ck.SameSite())
+
+	// Negative MaxAge deletes the cookie.
+	ctx.Response.Header.DelAllCookies()
+	assert.NoError(t, SetCookie(&http.Cookie{Name: "session", MaxAge: -1}, &ctx))
+	assert.True(t, ctx.Response.Header.Cookie(ck))
+	assert.True(t, fasthttp.CookieExpireDelete.Equal(ck.Expire()))
+
+	// Expiry is retained.
+	exp := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx.Response.Header.DelAllCookies()
+	assert.NoError(t, SetCookie(&http.Cookie{Name: "session", Expires: exp}, &ctx))
+	assert.True(t, ctx.Response.Header.Cookie(ck))
+	assert.True(t, exp.Equal(ck.Expire()))
+}
+
+func TestSameSite(t *testing.T) {
+	assert.Equal(t, fasthttp.CookieSameSiteDisabled, sameSite(0))
+	assert.Equal(t, fasthttp.CookieSameSiteDefaultMode, sameSite(http.SameSiteDefaultMode))
+	assert.Equal(t, fasthttp.CookieSameSiteLaxMode, sameSite(http.SameSiteLaxMode))
+	assert.Equal(t, fasthttp.CookieSameSiteStrictMode, sameSite(http.SameSiteStrictMode))
+	assert.Equal(t, fasthttp.CookieSameSiteNoneMode, sameSite(http.SameSiteNoneMode))
+}
+
+func TestHandler(t *testing.T) {
+	var (
+		str = newMockStore()
+		m   = newManager(str, true)
+	)
+
+	var (
+		sess  *simplesessions.Session
+		calls int
+	)
+	h := Handler(m, func(ctx *fasthttp.RequestCtx) {
+		calls++
+		sess = FromRequestCtx(ctx)
+		assert.NotNil(t, sess)
+
+		// Acquire with the RequestCtx returns the same session.
+		s, err := m.Acquire(ctx, ctx, ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, sess, s)
+		assert.NoError(t, sess.Set("foo", "bar"))
+	})
+
+	var ctx fasthttp.RequestCtx
+	h(&ctx)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, "bar", str.sessions[sess.ID()]["foo"])
+
+	ck := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(ck)
+	ck.SetKey("session")
+	assert.True(t, ctx.Response.Header.Cookie(ck))
+	assert.Equal(t, sess.ID(), string(ck.Value()))
+}
+
+func TestHandlerNoSession(t *testing.T) {
+	m := newManager(newMockStore(), false)
+
+	var called bool
+	h := Handler(m, func(ctx *fasthttp.RequestCtx) {
+		called = true
+		assert.Nil(t, FromRequestCtx(ctx))
+	})
+
+	var ctx fasthttp.RequestCtx
+	h(&ctx)
+	assert.True(t, called)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+}
+
+func TestHandlerError(t *testing.T) {
+	// No store hooks set on the manager.
+	m := simplesessions.New(simplesessions.Options{})
+	m.UseStore(newMockStore())
+
+	h := Handler(m, func(ctx *fasthttp.RequestCtx) {
+		t.Fatal("handler shouldn't be called")
+	})
+
+	var ctx fasthttp.RequestCtx
+	h(&ctx)
+	assert.Equal(t, fasthttp.StatusInternalServerError, ctx.Response.StatusCode())
+}
+
+func TestHandlerBuffered(t *testing.T) {
+	str := newMockStore()
+	m := simplesessions.New(simplesessions.Options{EnableAutoCreate: true, EnableBufferedWrites: true})
+	m.UseStore(str)
+	m.SetCookieHooks(GetCookie, SetCookie)
+
+	var sess *simplesessions.Session
+	h := Handler(m, func(ctx *fasthttp.RequestCtx) {
+		sess = FromRequestCtx(ctx)
+		assert.NoError(t, sess.Set("foo", "bar"))
+		assert.Nil(t, str.sessions[sess.ID()]["foo"])
+	})
+
+	var ctx fasthttp.RequestCtx
+	h(&ctx)
+	assert.Equal(t, "bar", str.sessions[sess.ID()]["foo"])
+
+	// Commit errors respond with 500.
+	h = Handler(m, func(ctx *fasthttp.RequestCtx) {
+		assert.NoError(t, FromRequestCtx(ctx).Set("foo", "baz"))
+		str.err = errors.New("store error")
+		ctx.SetBodyString("ok")
+	})
+
+	ctx = fasthttp.RequestCtx{}
+	h(&ctx)
+	assert.Equal(t, fasthttp.StatusInternalServerError, ctx.Response.StatusCode())
+	assert.NotContains(t, string(ctx.Response.Body()), "ok")
+}
@@ -0,0 +1,21 @@
+module github.com/zerodha/simplesessions/fasthttpadapter/v3
+
+go 1.21
+
+require (
+	github.com/stretchr/testify v1.9.0
+	github.com/valyala/fasthttp v1.47.0
+	github.com/zerodha/fastglue v1.8.0
+	github.com/zerodha/simplesessions/v3 v3.1.0
+)
+
+require (
+	github.com/andybalholm/brotli v1.0.5 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/fasthttp/router v1.4.5 // indirect
+	github.com/klauspost/compress v1.16.3 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/savsgio/gotils v0.0.0-20211223103454-d0aaa54c5899 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,159 @@
+package simplesessions
+
+import (
+	"context"
+)
+
+// flushStore applies the writes of a session to a store that implements Flusher
+// to the session's own copy of the data, which is encoded with Flusher.Encode().
+// The writes aren't buffered in the store as its buffer is keyed by the cookie
+// value and would be shared by concurrent requests with the same cookie.
+// Reads are passed on to the store until the data is loaded by the first write.
+type flushStore struct {
+	StoreContext
+	s *Session
+}
+
+func (f flushStore) CreateCtx(ctx context.Context, _ string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	f.s.dataMux.Lock()
+	f.s.data = make(map[string]interface{})
+	f.s.dataMux.Unlock()
+
+	return nil
+}
+
+func (f flushStore) GetCtx(ctx context.Context, id, key string) (interface{}, error) {
+	f.s.dataMux.Lock()
+	defer f.s.dataMux.Unlock()
+
+	if f.s.data == nil {
+		return f.StoreContext.GetCtx(ctx, id, key)
+	}
+
+	return f.s.data[key], nil
+}
+
+func (f flushStore) GetMultiCtx(ctx context.Context, id string, keys ...string) (map[string]interface{}, error) {
+	f.s.dataMux.Lock()
+	defer f.s.dataMux.Unlock()
+
+	if f.s.data == nil {
+		return f.StoreContext.GetMultiCtx(ctx, id, keys...)
+	}
+
+	out := make(map[string]interface{}, len(keys))
+	for _, k := range keys {
+		out[k] = f.s.data[k]
+	}
+
+	return out, nil
+}
+
+func (f flushStore) GetAllCtx(ctx context.Context, id string) (map[string]interface{}, error) {
+	f.s.dataMux.Lock()
+	defer f.s.dataMux.Unlock()
+
+	if f.s.data == nil {
+		return f.StoreContext.GetAllCtx(ctx, id)
+	}
+
+	out := make(map[string]interface{}, len(f.s.data))
+	for k, v := range f.s.data {
+		out[k] = v
+	}
+
+	return out, nil
+}
+
+func (f flushStore) SetCtx(ctx context.Context, id, key string, value interface{}) error {
+	return f.SetMultiCtx(ctx, id, map[string]interface{}{key: value})
+}
+
+func (f flushStore) SetMultiCtx(ctx context.Context, id string, data map[string]interface{}) error {
+	f.s.dataMux.Lock()
+	defer f.s.dataMux.Unlock()
+
+	if err := f.load(ctx, id); err != nil {
+		return err
+	}
+	for k, v := range data {
+		f.s.data[k] = v
+	}
+
+	return nil
+}
+
+func (f flushStore) DeleteCtx(ctx context.Context, id string, keys ...string) error {
+	f.s.dataMux.Lock()
+	defer f.s.dataMux.Unlock()
+
+	if err := f.load(ctx, id); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		delete(f.s.data, k)
+	}
+
+	return nil
+}
+
+func (f flushStore) ClearCtx(ctx context.Context, _ string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	f.s.dataMux.Lock()
+	f.s.data = make(map[string]interface{})
+	f.s.dataMux.Unlock()
+
+	return nil
+}
+
+// DestroyCtx discards the data. The session is destroyed by clearing the cookie.
+func (f flushStore) DestroyCtx(ctx context.Context, _ string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	f.s.dataMux.Lock()
+	f.s.data = nil
+	f.s.dataMux.Unlock()
+
+	return nil
+}
+
+// encode loads the data if it hasn't been yet and encodes it with the store.
+func (f flushStore) encode(ctx context.Context, st Flusher, id string) (string, error) {
+	f.s.dataMux.Lock()
+	defer f.s.dataMux.Unlock()
+
+	if err := f.load(ctx, id); err != nil {
+		return "", err
+	}
+
+	return st.Encode(f.s.data)
+}
+
+// load reads the session data from the store if it hasn't been read yet.
+// f.s.dataMux should be locked by the caller.
+func (f flushStore) load(ctx context.Context, id string) error {
+	if f.s.data != nil {
+		return nil
+	}
+
+	data, err := f.StoreContext.GetAllCtx(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	f.s.data = make(map[string]interface{}, len(data))
+	for k, v := range data {
+		f.s.data[k] = v
+	}
+
+	return nil
+}
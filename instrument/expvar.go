@@ -0,0 +1,162 @@
+package instrument
+
+import (
+	"expvar"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds of the latency histogram buckets used by
+// ExpvarRecorder if none are given.
+var DefaultBuckets = []time.Duration{
+	time.Millisecond / 2,
+	time.Millisecond,
+	time.Millisecond * 5,
+	time.Millisecond * 10,
+	time.Millisecond * 50,
+	time.Millisecond * 100,
+	time.Millisecond * 500,
+	time.Second,
+}
+
+// ExpvarRecorder is a Recorder that publishes the metrics with expvar, eg: on
+// /debug/vars, as a map of the store methods to their metrics:
+//
+//	{"Get": {"calls": 10, "errors": {"invalid_session": 1}, "latency": {...}}}
+//
+// The latency is a histogram with the cumulative count of calls that took up to
+// each bucket's upper bound in milliseconds, the total count and the sum in seconds:
+//
+//	{"buckets": {"0.5": 2, "1": 8, ..., "+Inf": 10}, "count": 10, "sum": 0.012}
+type ExpvarRecorder struct {
+	vars    *expvar.Map
+	buckets []time.Duration
+
+	mu      sync.RWMutex
+	methods map[string]*methodVars
+}
+
+// methodVars are the metrics of a store method.
+type methodVars struct {
+	calls   *expvar.Int
+	errors  *expvar.Map
+	latency *histogram
+}
+
+// NewExpvarRecorder returns a new ExpvarRecorder that publishes the metrics under
+// the given name. As with expvar.Publish(), it panics if the name is already in use.
+// If buckets is nil, DefaultBuckets are used. Buckets should be in increasing order.
+func NewExpvarRecorder(name string, buckets []time.Duration) *ExpvarRecorder {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+
+	return &ExpvarRecorder{
+		vars:    expvar.NewMap(name),
+		buckets: buckets,
+		methods: make(map[string]*methodVars),
+	}
+}
+
+// Record records a call to the store method.
+func (r *ExpvarRecorder) Record(method string, took time.Duration, category string) {
+	m := r.method(method)
+
+	m.calls.Add(1)
+	if category != "" {
+		m.errors.Add(category, 1)
+	}
+	m.latency.observe(took)
+}
+
+// method returns the metrics of the method, creating them if they don't exist.
+func (r *ExpvarRecorder) method(name string) *methodVars {
+	r.mu.RLock()
+	m, ok := r.methods[name]
+	r.mu.RUnlock()
+	if ok {
+		return m
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if m, ok := r.methods[name]; ok {
+		return m
+	}
+
+	m = &methodVars{
+		calls:   new(expvar.Int),
+		errors:  new(expvar.Map).Init(),
+		latency: newHistogram(r.buckets),
+	}
+
+	vars := new(expvar.Map).Init()
+	vars.Set("calls", m.calls)
+	vars.Set("errors", m.errors)
+	vars.Set("latency", m.latency)
+	r.vars.Set(name, vars)
+	r.methods[name] = m
+
+	return m
+}
+
+// histogram is a latency histogram that implements expvar.Var.
+type histogram struct {
+	bounds []time.Duration
+
+	// Number of observations in each bucket, the last one being +Inf.
+	counts []uint64
+	count  uint64
+	// Sum of the observations in nanoseconds.
+	sum int64
+}
+
+func newHistogram(bounds []time.Duration) *histogram {
+	return &histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(h.bounds) && d > h.bounds[i] {
+		i++
+	}
+
+	atomic.AddUint64(&h.counts[i], 1)
+	atomic.AddUint64(&h.count, 1)
+	atomic.AddInt64(&h.sum, int64(d))
+}
+
+// String returns the histogram as JSON with cumulative bucket counts.
+func (h *histogram) String() string {
+	var (
+		b     strings.Builder
+		total uint64
+	)
+
+	b.WriteString(`{"buckets": {`)
+	for i := range h.counts {
+		total += atomic.LoadUint64(&h.counts[i])
+		if i > 0 {
+			b.WriteString(", ")
+		}
+
+		le := "+Inf"
+		if i < len(h.bounds) {
+			le = strconv.FormatFloat(float64(h.bounds[i])/float64(time.Millisecond), 'f', -1, 64)
+		}
+		b.WriteString(`"` + le + `": ` + strconv.FormatUint(total, 10))
+	}
+
+	sum := time.Duration(atomic.LoadInt64(&h.sum)).Seconds()
+	b.WriteString(`}, "count": ` + strconv.FormatUint(atomic.LoadUint64(&h.count), 10))
+	b.WriteString(`, "sum": ` + strconv.FormatFloat(sum, 'f', -1, 64) + `}`)
+
+	return b.String()
+}
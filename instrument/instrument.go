@@ -0,0 +1,321 @@
+// Package instrument wraps a simplesessions.Store to record the number of calls,
+// errors and latency of its methods, eg: to monitor the load on the backend.
+// Metrics are recorded with a Recorder. ExpvarRecorder publishes them with expvar.
+//
+//	str := instrument.New(redis.New(ctx, client), instrument.NewExpvarRecorder("sessions", nil))
+//	sessMan.UseStore(str)
+package instrument
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/zerodha/simplesessions/v3"
+)
+
+// Categories of the errors returned by the store, as they're mapped by the Session.
+const (
+	CategoryInvalidSession = "invalid_session"
+	CategoryNil            = "nil"
+	CategoryAssertType     = "assert_type"
+
+	// CategoryOther is any other error, eg: a network error.
+	CategoryOther = "other"
+)
+
+// Recorder records the calls to the store. It should be safe for concurrent use.
+type Recorder interface {
+	// Record records a call to the store method, eg: "Get", that took the given
+	// duration. category is the category of the error returned by the store,
+	// eg: CategoryInvalidSession, and is empty if the call succeeded.
+	Record(method string, took time.Duration, category string)
+}
+
+// Store wraps a store and records the calls to its methods with a Recorder.
+// Calls to the context aware methods, eg: GetCtx(), are recorded as their
+// Store counterparts, eg: "Get". The type helpers, eg: Int(), aren't recorded.
+//
+// The optional interfaces that the wrapped store implements, eg: Renamer, are
+// used by the Manager through Unwrap(). Their calls aren't recorded.
+type Store struct {
+	st  simplesessions.Store
+	ctx simplesessions.StoreContext
+	rec Recorder
+}
+
+var (
+	_ simplesessions.StoreContext = (*Store)(nil)
+	_ simplesessions.Unwrapper    = (*Store)(nil)
+)
+
+// New returns a new Store that wraps the given store and records its calls with rec.
+func New(st simplesessions.Store, rec Recorder) *Store {
+	s := &Store{
+		st:  st,
+		rec: rec,
+	}
+	if c, ok := st.(simplesessions.StoreContext); ok {
+		s.ctx = c
+	}
+
+	return s
+}
+
+// Unwrap returns the wrapped store.
+func (s *Store) Unwrap() simplesessions.Store {
+	return s.st
+}
+
+// Category returns the category of the error returned by a store. It's empty if err is nil.
+func Category(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	// Stores may return errors with just a code, which don't match the errors with errors.Is().
+	var e interface{ Code() int }
+	if errors.As(err, &e) {
+		switch e.Code() {
+		case simplesessions.CodeInvalidSession:
+			return CategoryInvalidSession
+		case simplesessions.CodeNil:
+			return CategoryNil
+		case simplesessions.CodeAssertType:
+			return CategoryAssertType
+		}
+	}
+
+	switch {
+	case errors.Is(err, simplesessions.ErrInvalidSession):
+		return CategoryInvalidSession
+	case errors.Is(err, simplesessions.ErrNil):
+		return CategoryNil
+	case errors.Is(err, simplesessions.ErrAssertType):
+		return CategoryAssertType
+	}
+
+	return CategoryOther
+}
+
+// record records the call to the method that started at the given time.
+func (s *Store) record(method string, start time.Time, err error) {
+	s.rec.Record(method, time.Since(start), Category(err))
+}
+
+// Create creates a session in the wrapped store and records the call.
+func (s *Store) Create(id string) error {
+	start := time.Now()
+	err := s.st.Create(id)
+	s.record("Create", start, err)
+	return err
+}
+
+// Get returns a field value of the session from the wrapped store and records the call.
+func (s *Store) Get(id, key string) (interface{}, error) {
+	start := time.Now()
+	v, err := s.st.Get(id, key)
+	s.record("Get", start, err)
+	return v, err
+}
+
+// GetMulti returns the values of the given fields of the session from the wrapped store and records the call.
+func (s *Store) GetMulti(id string, keys ...string) (map[string]interface{}, error) {
+	start := time.Now()
+	v, err := s.st.GetMulti(id, keys...)
+	s.record("GetMulti", start, err)
+	return v, err
+}
+
+// GetAll returns all the fields of the session from the wrapped store and records the call.
+func (s *Store) GetAll(id string) (map[string]interface{}, error) {
+	start := time.Now()
+	v, err := s.st.GetAll(id)
+	s.record("GetAll", start, err)
+	return v, err
+}
+
+// Set sets a field of the session in the wrapped store and records the call.
+func (s *Store) Set(id, key string, value interface{}) error {
+	start := time.Now()
+	err := s.st.Set(id, key, value)
+	s.record("Set", start, err)
+	return err
+}
+
+// SetMulti sets the given fields of the session in the wrapped store and records the call.
+func (s *Store) SetMulti(id string, data map[string]interface{}) error {
+	start := time.Now()
+	err := s.st.SetMulti(id, data)
+	s.record("SetMulti", start, err)
+	return err
+}
+
+// Delete deletes the given fields of the session from the wrapped store and records the call.
+func (s *Store) Delete(id string, key ...string) error {
+	start := time.Now()
+	err := s.st.Delete(id, key...)
+	s.record("Delete", start, err)
+	return err
+}
+
+// Clear clears the session in the wrapped store and records the call.
+func (s *Store) Clear(id string) error {
+	start := time.Now()
+	err := s.st.Clear(id)
+	s.record("Clear", start, err)
+	return err
+}
+
+// Destroy destroys the session in the wrapped store and records the call.
+func (s *Store) Destroy(id string) error {
+	start := time.Now()
+	err := s.st.Destroy(id)
+	s.record("Destroy", start, err)
+	return err
+}
+
+// CreateCtx calls the wrapped store's CreateCtx() if it implements
+// simplesessions.StoreContext and Create() otherwise. This applies
+// to all the context aware methods.
+func (s *Store) CreateCtx(ctx context.Context, id string) error {
+	if s.ctx == nil {
+		return s.Create(id)
+	}
+
+	start := time.Now()
+	err := s.ctx.CreateCtx(ctx, id)
+	s.record("Create", start, err)
+	return err
+}
+
+// GetCtx is the context aware version of Get.
+func (s *Store) GetCtx(ctx context.Context, id, key string) (interface{}, error) {
+	if s.ctx == nil {
+		return s.Get(id, key)
+	}
+
+	start := time.Now()
+	v, err := s.ctx.GetCtx(ctx, id, key)
+	s.record("Get", start, err)
+	return v, err
+}
+
+// GetMultiCtx is the context aware version of GetMulti.
+func (s *Store) GetMultiCtx(ctx context.Context, id string, keys ...string) (map[string]interface{}, error) {
+	if s.ctx == nil {
+		return s.GetMulti(id, keys...)
+	}
+
+	start := time.Now()
+	v, err := s.ctx.GetMultiCtx(ctx, id, keys...)
+	s.record("GetMulti", start, err)
+	return v, err
+}
+
+// GetAllCtx is the context aware version of GetAll.
+func (s *Store) GetAllCtx(ctx context.Context, id string) (map[string]interface{}, error) {
+	if s.ctx == nil {
+		return s.GetAll(id)
+	}
+
+	start := time.Now()
+	v, err := s.ctx.GetAllCtx(ctx, id)
+	s.record("GetAll", start, err)
+	return v, err
+}
+
+// SetCtx is the context aware version of Set.
+func (s *Store) SetCtx(ctx context.Context, id, key string, value interface{}) error {
+	if s.ctx == nil {
+		return s.Set(id, key, value)
+	}
+
+	start := time.Now()
+	err := s.ctx.SetCtx(ctx, id, key, value)
+	s.record("Set", start, err)
+	return err
+}
+
+// SetMultiCtx is the context aware version of SetMulti.
+func (s *Store) SetMultiCtx(ctx context.Context, id string, data map[string]interface{}) error {
+	if s.ctx == nil {
+		return s.SetMulti(id, data)
+	}
+
+	start := time.Now()
+	err := s.ctx.SetMultiCtx(ctx, id, data)
+	s.record("SetMulti", start, err)
+	return err
+}
+
+// DeleteCtx is the context aware version of Delete.
+func (s *Store) DeleteCtx(ctx context.Context, id string, key ...string) error {
+	if s.ctx == nil {
+		return s.Delete(id, key...)
+	}
+
+	start := time.Now()
+	err := s.ctx.DeleteCtx(ctx, id, key...)
+	s.record("Delete", start, err)
+	return err
+}
+
+// ClearCtx is the context aware version of Clear.
+func (s *Store) ClearCtx(ctx context.Context, id string) error {
+	if s.ctx == nil {
+		return s.Clear(id)
+	}
+
+	start := time.Now()
+	err := s.ctx.ClearCtx(ctx, id)
+	s.record("Clear", start, err)
+	return err
+}
+
+// DestroyCtx is the context aware version of Destroy.
+func (s *Store) DestroyCtx(ctx context.Context, id string) error {
+	if s.ctx == nil {
+		return s.Destroy(id)
+	}
+
+	start := time.Now()
+	err := s.ctx.DestroyCtx(ctx, id)
+	s.record("Destroy", start, err)
+	return err
+}
+
+// Int is a helper method to type assert as integer with the wrapped store.
+func (s *Store) Int(r interface{}, err error) (int, error) {
+	return s.st.Int(r, err)
+}
+
+// Int64 is a helper method to type assert as Int64 with the wrapped store.
+func (s *Store) Int64(r interface{}, err error) (int64, error) {
+	return s.st.Int64(r, err)
+}
+
+// UInt64 is a helper method to type assert as UInt64 with the wrapped store.
+func (s *Store) UInt64(r interface{}, err error) (uint64, error) {
+	return s.st.UInt64(r, err)
+}
+
+// Float64 is a helper method to type assert as Float64 with the wrapped store.
+func (s *Store) Float64(r interface{}, err error) (float64, error) {
+	return s.st.Float64(r, err)
+}
+
+// String is a helper method to type assert as String with the wrapped store.
+func (s *Store) String(r interface{}, err error) (string, error) {
+	return s.st.String(r, err)
+}
+
+// Bytes is a helper method to type assert as Bytes with the wrapped store.
+func (s *Store) Bytes(r interface{}, err error) ([]byte, error) {
+	return s.st.Bytes(r, err)
+}
+
+// Bool is a helper method to type assert as Bool with the wrapped store.
+func (s *Store) Bool(r interface{}, err error) (bool, error) {
+	return s.st.Bool(r, err)
+}
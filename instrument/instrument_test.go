@@ -0,0 +1,160 @@
+package instrument
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerodha/simplesessions/v3"
+)
+
+var errTest = errors.New("test error")
+
+// fakeStore implements the methods of simplesessions.Store used in the tests.
+type fakeStore struct {
+	simplesessions.Store
+	err error
+}
+
+func (s *fakeStore) Get(id, key string) (interface{}, error) {
+	return "bar", s.err
+}
+
+func (s *fakeStore) Set(id, key string, value interface{}) error {
+	return s.err
+}
+
+func (s *fakeStore) Int(r interface{}, err error) (int, error) {
+	return 10, err
+}
+
+// fakeCtxStore additionally implements GetCtx of simplesessions.StoreContext.
+type fakeCtxStore struct {
+	*fakeStore
+	simplesessions.StoreContext
+	ctx context.Context
+}
+
+func (s *fakeCtxStore) GetCtx(ctx context.Context, id, key string) (interface{}, error) {
+	s.ctx = ctx
+	return s.Get(id, key)
+}
+
+type call struct {
+	method   string
+	category string
+}
+
+type fakeRecorder struct {
+	mu    sync.Mutex
+	calls []call
+}
+
+func (r *fakeRecorder) Record(method string, took time.Duration, category string) {
+	r.mu.Lock()
+	r.calls = append(r.calls, call{method, category})
+	r.mu.Unlock()
+}
+
+func TestStore(t *testing.T) {
+	var (
+		st  = &fakeStore{}
+		rec = &fakeRecorder{}
+		str = New(st, rec)
+	)
+	assert.Equal(t, st, str.Unwrap())
+
+	v, err := str.Get("id", "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", v)
+
+	// Without StoreContext, the context methods fall back to the Store methods.
+	_, err = str.GetCtx(context.Background(), "id", "foo")
+	assert.NoError(t, err)
+
+	st.err = simplesessions.NewStoreError(simplesessions.CodeInvalidSession, "invalid session")
+	assert.ErrorIs(t, str.Set("id", "foo", "bar"), st.err)
+
+	st.err = errTest
+	assert.ErrorIs(t, str.Set("id", "foo", "bar"), errTest)
+
+	// Helpers aren't recorded.
+	n, err := str.Int(str.Get("id", "foo"))
+	assert.ErrorIs(t, err, errTest)
+	assert.Equal(t, 10, n)
+
+	assert.Equal(t, []call{
+		{"Get", ""},
+		{"Get", ""},
+		{"Set", CategoryInvalidSession},
+		{"Set", CategoryOther},
+		{"Get", CategoryOther},
+	}, rec.calls)
+}
+
+func TestStoreContext(t *testing.T) {
+	var (
+		st  = &fakeCtxStore{fakeStore: &fakeStore{}}
+		rec = &fakeRecorder{}
+		str = New(st, rec)
+	)
+
+	ctx := context.WithValue(context.Background(), struct{}{}, "1")
+	_, err := str.GetCtx(ctx, "id", "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, ctx, st.ctx)
+	assert.Equal(t, []call{{"Get", ""}}, rec.calls)
+}
+
+func TestCategory(t *testing.T) {
+	for err, cat := range map[error]string{
+		nil:                                     "",
+		simplesessions.ErrInvalidSession:        CategoryInvalidSession,
+		simplesessions.ErrNil:                   CategoryNil,
+		simplesessions.ErrAssertType:            CategoryAssertType,
+		fmt.Errorf("%w", simplesessions.ErrNil): CategoryNil,
+		simplesessions.NewStoreError(simplesessions.CodeAssertType, "assertion failed").Wrap(errTest): CategoryAssertType,
+		errTest: CategoryOther,
+	} {
+		assert.Equal(t, cat, Category(err), err)
+	}
+}
+
+func TestExpvarRecorder(t *testing.T) {
+	rec := NewExpvarRecorder("instrument_test", []time.Duration{time.Millisecond, time.Millisecond * 10})
+
+	rec.Record("Get", time.Millisecond/2, "")
+	rec.Record("Get", time.Millisecond*5, CategoryInvalidSession)
+	rec.Record("Get", time.Second, CategoryInvalidSession)
+	rec.Record("Set", time.Millisecond, CategoryOther)
+
+	var out map[string]struct {
+		Calls   int            `json:"calls"`
+		Errors  map[string]int `json:"errors"`
+		Latency struct {
+			Buckets map[string]int `json:"buckets"`
+			Count   int            `json:"count"`
+			Sum     float64        `json:"sum"`
+		} `json:"latency"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(expvar.Get("instrument_test").String()), &out))
+
+	get := out["Get"]
+	assert.Equal(t, 3, get.Calls)
+	assert.Equal(t, map[string]int{CategoryInvalidSession: 2}, get.Errors)
+	assert.Equal(t, map[string]int{"1": 1, "10": 2, "+Inf": 3}, get.Latency.Buckets)
+	assert.Equal(t, 3, get.Latency.Count)
+	assert.InDelta(t, 1.0055, get.Latency.Sum, 1e-9)
+
+	set := out["Set"]
+	assert.Equal(t, 1, set.Calls)
+	assert.Equal(t, map[string]int{CategoryOther: 1}, set.Errors)
+	assert.Equal(t, map[string]int{"1": 1, "10": 1, "+Inf": 1}, set.Latency.Buckets)
+}
@@ -0,0 +1,111 @@
+package simplesessions
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// SetLogger sets the logger for structured logs of session creation, acquisition
+// misses, expiry, store errors and cookie writes. Store errors are logged at the
+// error level, except invalid sessions and context errors, eg: from canceled requests,
+// which are logged at the debug level like the rest. Session IDs are redacted with RedactID(). Logging is disabled by default.
+func (m *Manager) SetLogger(l *slog.Logger) {
+	m.log = l
+}
+
+// RedactID returns the session ID with all but its first 4 characters masked
+// so that it can be logged without exposing the session.
+func RedactID(id string) string {
+	if len(id) <= 4 {
+		return "***"
+	}
+
+	return id[:4] + "***"
+}
+
+// debug logs a debug record for the session ID, if a logger is set.
+func (m *Manager) debug(ctx context.Context, msg, id string, attrs ...slog.Attr) {
+	if m.log == nil {
+		return
+	}
+
+	m.log.LogAttrs(ctx, slog.LevelDebug, msg, append([]slog.Attr{slog.String("session_id", RedactID(id))}, attrs...)...)
+}
+
+// logErr logs the error returned by the store for the operation, if a logger is set.
+func (m *Manager) logErr(ctx context.Context, op, id string, err error) {
+	if m.log == nil || err == nil {
+		return
+	}
+
+	// Invalid sessions and canceled requests, eg: client disconnects, are expected.
+	level := slog.LevelError
+	if errors.Is(errAs(err), ErrInvalidSession) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		level = slog.LevelDebug
+	}
+	m.log.LogAttrs(ctx, level, "store error",
+		slog.String("op", op),
+		slog.String("session_id", RedactID(id)),
+		slog.Any("error", err))
+}
+
+// logStore wraps the store to log the errors it returns.
+type logStore struct {
+	StoreContext
+	m *Manager
+}
+
+func (s logStore) CreateCtx(ctx context.Context, id string) error {
+	err := s.StoreContext.CreateCtx(ctx, id)
+	s.m.logErr(ctx, "create", id, err)
+	return err
+}
+
+func (s logStore) GetCtx(ctx context.Context, id, key string) (interface{}, error) {
+	v, err := s.StoreContext.GetCtx(ctx, id, key)
+	s.m.logErr(ctx, "get", id, err)
+	return v, err
+}
+
+func (s logStore) GetMultiCtx(ctx context.Context, id string, keys ...string) (map[string]interface{}, error) {
+	v, err := s.StoreContext.GetMultiCtx(ctx, id, keys...)
+	s.m.logErr(ctx, "get_multi", id, err)
+	return v, err
+}
+
+func (s logStore) GetAllCtx(ctx context.Context, id string) (map[string]interface{}, error) {
+	v, err := s.StoreContext.GetAllCtx(ctx, id)
+	s.m.logErr(ctx, "get_all", id, err)
+	return v, err
+}
+
+func (s logStore) SetCtx(ctx context.Context, id, key string, value interface{}) error {
+	err := s.StoreContext.SetCtx(ctx, id, key, value)
+	s.m.logErr(ctx, "set", id, err)
+	return err
+}
+
+func (s logStore) SetMultiCtx(ctx context.Context, id string, data map[string]interface{}) error {
+	err := s.StoreContext.SetMultiCtx(ctx, id, data)
+	s.m.logErr(ctx, "set_multi", id, err)
+	return err
+}
+
+func (s logStore) DeleteCtx(ctx context.Context, id string, key ...string) error {
+	err := s.StoreContext.DeleteCtx(ctx, id, key...)
+	s.m.logErr(ctx, "delete", id, err)
+	return err
+}
+
+func (s logStore) ClearCtx(ctx context.Context, id string) error {
+	err := s.StoreContext.ClearCtx(ctx, id)
+	s.m.logErr(ctx, "clear", id, err)
+	return err
+}
+
+func (s logStore) DestroyCtx(ctx context.Context, id string) error {
+	err := s.StoreContext.DestroyCtx(ctx, id)
+	s.m.logErr(ctx, "destroy", id, err)
+	return err
+}
@@ -0,0 +1,104 @@
+package simplesessions
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactID(t *testing.T) {
+	assert.Equal(t, "some***", RedactID(mockSessionID))
+	assert.Equal(t, "***", RedactID("abcd"))
+	assert.Equal(t, "***", RedactID(""))
+}
+
+func TestSetLogger(t *testing.T) {
+	var (
+		buf bytes.Buffer
+		str = newMockStore()
+		m   = New(Options{})
+	)
+	m.UseStore(str)
+	m.SetCookieHooks(mockGetCookieCb, mockSetCookieCb)
+
+	// Logging is disabled by default.
+	_, ok := m.storeCtx().(logStore)
+	assert.False(t, ok)
+
+	m.SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	_, ok = m.storeCtx().(logStore)
+	assert.True(t, ok)
+
+	sess, err := m.NewSession(nil, nil)
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), `msg="cookie written" session_id=`+RedactID(sess.ID())+" cookie=session")
+	assert.Contains(t, buf.String(), `msg="session created" session_id=`+RedactID(sess.ID()))
+	assert.NotContains(t, buf.String(), sess.ID())
+
+	// Store errors are logged with the operation.
+	buf.Reset()
+	str.err = errors.New("db down")
+	assert.Error(t, sess.Set("foo", "bar"))
+	assert.Contains(t, buf.String(), `level=ERROR msg="store error" op=set session_id=`+RedactID(sess.ID())+` error="db down"`)
+	str.err = nil
+
+	// Invalid sessions are logged at the debug level.
+	buf.Reset()
+	str.id = ""
+	_, err = sess.Get("foo")
+	assert.ErrorIs(t, err, ErrInvalidSession)
+	assert.Contains(t, buf.String(), `level=DEBUG msg="store error" op=get`)
+
+	// So are context errors, eg: from canceled requests.
+	str.id = mockSessionID
+	for _, err := range []error{context.Canceled, context.DeadlineExceeded} {
+		buf.Reset()
+		str.err = fmt.Errorf("get: %w", err)
+		assert.ErrorIs(t, sess.Set("foo", "bar"), err)
+		assert.Contains(t, buf.String(), `level=DEBUG msg="store error" op=set`)
+	}
+	str.err = nil
+
+	assert.NoError(t, sess.ClearCookie())
+	assert.Contains(t, buf.String(), `msg="cookie cleared"`)
+}
+
+func TestLoggerAcquire(t *testing.T) {
+	var (
+		buf bytes.Buffer
+		m   = New(Options{})
+	)
+	m.UseStore(newMockStore())
+	m.SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	// No cookie.
+	m.SetCookieHooks(func(string, interface{}) (*http.Cookie, error) {
+		return nil, http.ErrNoCookie
+	}, mockSetCookieCb)
+	_, err := m.Acquire(context.Background(), nil, nil)
+	assert.ErrorIs(t, err, ErrInvalidSession)
+	assert.Contains(t, buf.String(), `msg="session not acquired" session_id=*** reason="no cookie"`)
+
+	// Malformed IDs are redacted.
+	buf.Reset()
+	m.SetCookieHooks(func(name string, _ interface{}) (*http.Cookie, error) {
+		return &http.Cookie{Name: name, Value: "bad$cookie"}, nil
+	}, mockSetCookieCb)
+	_, err = m.Acquire(context.Background(), nil, nil)
+	assert.ErrorIs(t, err, ErrInvalidSession)
+	assert.Contains(t, buf.String(), `msg="session not acquired" session_id=bad$*** reason="invalid id"`)
+	assert.NotContains(t, buf.String(), "bad$cookie")
+
+	// Acquired sessions aren't logged.
+	buf.Reset()
+	m.SetCookieHooks(mockGetCookieCb, mockSetCookieCb)
+	_, err = m.Acquire(context.Background(), nil, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, buf.String())
+}
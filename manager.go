@@ -3,7 +3,9 @@ package simplesessions
 import (
 	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
 	"unicode"
@@ -45,6 +47,12 @@ type Manager struct {
 
 	// validate cookie ID.
 	validateID func(string) bool
+
+	// Hooks called on session lifecycle events.
+	hooks []func(Event)
+
+	// Logger for structured logs. Disabled if nil.
+	log *slog.Logger
 }
 
 // Options to configure manager and cookie.
@@ -58,10 +66,60 @@ type Options struct {
 	// Also not applicable if custom generateID and validateID is set.
 	SessionIDLength int
 
+	// IdleTimeout expires a session if it isn't acquired for the given duration.
+	// Disabled if 0. The last seen time is stored in the session as reserved metadata.
+	// It's only updated once it's older than a tenth of the timeout to avoid a write
+	// on every request, so sessions expire between 90% and 100% of the timeout after
+	// they were last acquired.
+	IdleTimeout time.Duration
+
+	// AbsoluteTimeout expires a session after the given duration since its creation,
+	// regardless of activity. Disabled if 0. The creation time is stored in the session
+	// as reserved metadata. Sessions whose timestamps can't be read are expired.
+	AbsoluteTimeout time.Duration
+
+	// EnableBufferedWrites buffers Set, SetMulti and Delete calls in the session
+	// instead of writing them to the store right away. Buffered writes are flushed
+	// to the store with a single `SetMulti` and `Delete` by `Session.Commit()`.
+	// Reads on the session reflect the buffered writes.
+	EnableBufferedWrites bool
+
+	// MaxSessionsPerUser limits the number of sessions a user can have at a time, eg: to
+	// limit the number of devices. It's enforced by `Session.SetOwner()` as per
+	// SessionLimitPolicy and requires a store that implements `UserIndexStore`.
+	// The limit is best-effort as concurrent logins of a user aren't serialized.
+	// Unlimited if 0.
+	MaxSessionsPerUser int
+
+	// SessionLimitPolicy decides how a session that exceeds MaxSessionsPerUser
+	// is handled. Defaults to EvictOldest.
+	SessionLimitPolicy SessionLimitPolicy
+
+	// OnEvict is called with the user ID and the IDs of the sessions destroyed
+	// by the EvictOldest policy to make room for a new session of the user.
+	OnEvict func(userID string, ids []string)
+
+	// Codec encodes and decodes values of types that the stores don't support natively,
+	// eg: structs, in `GetAs()` and `SetAs()`. Defaults to JSONValueCodec.
+	Codec ValueCodec
+
 	// Cookie options.
 	Cookie CookieOptions
 }
 
+// SessionLimitPolicy is the action taken when a user exceeds `Options.MaxSessionsPerUser`.
+type SessionLimitPolicy int
+
+const (
+	// EvictOldest destroys the oldest sessions of the user, by the time they were
+	// assigned to the user, to make room for the new session.
+	EvictOldest SessionLimitPolicy = iota
+
+	// RejectNew rejects the new session with ErrSessionLimit. The session
+	// is left without an owner and should be destroyed by the caller.
+	RejectNew
+)
+
 type CookieOptions struct {
 	// Name sets http cookie name. This is also sent as cookie name in `GetCookie` callback.
 	Name string
@@ -114,6 +172,10 @@ func New(opts Options) *Manager {
 		m.opts.SessionIDLength = defaultSessIDLength
 	}
 
+	if m.opts.Codec == nil {
+		m.opts.Codec = JSONValueCodec
+	}
+
 	// Assign default set and validate generate ID.
 	m.generateID = m.defaultGenerateID
 	m.validateID = m.defaultValidateID
@@ -144,6 +206,7 @@ func (m *Manager) SetCookieHooks(getCookie func(string, interface{}) (*http.Cook
 // Bydefault alpha-numeric 32bit length session ID is used if its not set.
 // - Generating custom session ID, which will be uses as the ID for storing sessions in the backend.
 // - Validating custom session ID, which will be used to verify the ID before querying backend.
+// The validator is ignored if the store implements `IDValidator`.
 func (m *Manager) SetSessionIDHooks(generateID func() (string, error), validateID func(string) bool) {
 	m.generateID = generateID
 	m.validateID = validateID
@@ -152,6 +215,13 @@ func (m *Manager) SetSessionIDHooks(generateID func() (string, error), validateI
 // NewSession creates a new `Session` and updates the cookie with a new session ID,
 // replacing any existing session ID if it exists.
 func (m *Manager) NewSession(r, w interface{}) (*Session, error) {
+	return m.newSession(context.Background(), r, w)
+}
+
+// newSession creates a new `Session` bound to the given context.
+func (m *Manager) newSession(c context.Context, r, w interface{}) (*Session, error) {
+	start := time.Now()
+
 	// Check if any store is set
 	if m.store == nil {
 		return nil, fmt.Errorf("session store not set")
@@ -165,24 +235,39 @@ func (m *Manager) NewSession(r, w interface{}) (*Session, error) {
 	// Store also calls `WriteCookie`` to write to http interface.
 	id, err := m.generateID()
 	if err != nil {
-		return nil, errAs(err)
-	}
-
-	if err = m.store.Create(id); err != nil {
+		if m.log != nil {
+			m.log.ErrorContext(c, "error generating session ID", slog.Any("error", err))
+		}
 		return nil, errAs(err)
 	}
 
 	var sess = &Session{
 		id:      id,
 		manager: m,
+		ctx:     c,
 		reader:  r,
 		writer:  w,
 		cache:   nil,
 	}
+
+	if err = sess.store().CreateCtx(c, id); err != nil {
+		return nil, errAs(err)
+	}
+
+	// Record the timestamps for enforcing timeouts.
+	if m.hasTimeouts() {
+		now := time.Now().Unix()
+		if err := sess.setMeta(map[string]interface{}{keyCreatedAt: now, keyLastSeen: now}); err != nil {
+			return nil, err
+		}
+	}
+
 	// Write cookie.
-	if err := sess.WriteCookie(id); err != nil {
+	if err := sess.writeID(); err != nil {
 		return nil, err
 	}
+	sess.emit(EventCreate, start)
+	m.debug(c, "session created", id)
 
 	return sess, nil
 }
@@ -191,9 +276,13 @@ func (m *Manager) NewSession(r, w interface{}) (*Session, error) {
 //
 // If session not found and `opt.EnableAutoCreate` is true, a new session is created and returned.
 // If session not found and `opt.EnableAutoCreate` is false which is the default, it returns `ErrInvalidSession`.
+// A session cookie that fails ID validation is treated as not found.
+// If `opt.IdleTimeout` or `opt.AbsoluteTimeout` is set, the session is checked in the store
+// and expired sessions are destroyed and treated as not found.
 //
 // `r` and `w` are request and response interfaces which is passed back in in GetCookie and SetCookie callbacks.
 // Optionally, a context can be passed to get an already loaded session, useful in middleware chains.
+// The context is also passed on to stores that implement `StoreContext`.
 func (m *Manager) Acquire(c context.Context, r, w interface{}) (*Session, error) {
 	// Check if any store is set
 	if m.store == nil {
@@ -214,20 +303,39 @@ func (m *Manager) Acquire(c context.Context, r, w interface{}) (*Session, error)
 		if v, ok := c.Value(ContextName).(*Session); ok {
 			return v, nil
 		}
+	} else {
+		c = context.Background()
 	}
+	start := time.Now()
 
 	// Get existing HTTP session cookie.
-	// If there's no error and there's a session ID (unvalidated at this point),
-	// return a session object.
+	// If there's no error and there's a valid session ID, return a session object.
+	// Malformed IDs are treated as if there's no session and are never sent to the store.
 	ck, err := m.getCookieHook(m.opts.Cookie.Name, r)
-	if err == nil && ck != nil && ck.Value != "" {
-		return &Session{
+	switch {
+	case err != nil || ck == nil || ck.Value == "":
+		m.debug(c, "session not acquired", "", slog.String("reason", "no cookie"))
+	case !m.isValidID(ck.Value):
+		m.debug(c, "session not acquired", ck.Value, slog.String("reason", "invalid id"))
+	default:
+		sess := &Session{
 			manager: m,
+			ctx:     c,
 			reader:  r,
 			writer:  w,
 			id:      ck.Value,
 			cache:   nil,
-		}, nil
+		}
+
+		err := sess.checkExpiry()
+		if err == nil {
+			sess.emit(EventAcquire, start)
+			return sess, nil
+		}
+		if !errors.Is(err, ErrInvalidSession) {
+			return nil, err
+		}
+		m.debug(c, "session not acquired", sess.id, slog.String("reason", "invalid session"))
 	}
 
 	// If auto-creation is disabled, return an error.
@@ -235,7 +343,164 @@ func (m *Manager) Acquire(c context.Context, r, w interface{}) (*Session, error)
 		return nil, ErrInvalidSession
 	}
 
-	return m.NewSession(r, w)
+	return m.newSession(c, r, w)
+}
+
+// ListUserSessions returns the IDs of the live sessions owned by the given user.
+// See `Session.SetOwner()`. ErrUserIndexUnsupported is returned if the store
+// doesn't implement `UserIndexStore`.
+func (m *Manager) ListUserSessions(userID string) ([]string, error) {
+	u, ok := m.userIndex()
+	if !ok {
+		return nil, ErrUserIndexUnsupported
+	}
+
+	ids, err := u.UserSessionsCtx(context.Background(), userID)
+	if err != nil {
+		return nil, errAs(err)
+	}
+
+	return ids, nil
+}
+
+// RevokeUserSessions destroys all the sessions owned by the given user except the
+// given session IDs, eg: the ID of the current session to log out of all other devices.
+// Sessions that have already been destroyed are ignored. ErrUserIndexUnsupported
+// is returned if the store doesn't implement `UserIndexStore`.
+func (m *Manager) RevokeUserSessions(userID string, except ...string) error {
+	ids, err := m.ListUserSessions(userID)
+	if err != nil {
+		return err
+	}
+
+	skip := make(map[string]struct{}, len(except))
+	for _, id := range except {
+		skip[id] = struct{}{}
+	}
+
+	for _, id := range ids {
+		if _, ok := skip[id]; ok {
+			continue
+		}
+
+		if _, err := m.destroy(id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// limitUserSessions enforces `Options.MaxSessionsPerUser` before the session with
+// the given ID is assigned to the user and returns the IDs of the sessions that
+// have to be evicted once it is.
+func (m *Manager) limitUserSessions(ctx context.Context, u UserIndexStoreContext, id, userID string) ([]string, error) {
+	ids, err := u.UserSessionsCtx(ctx, userID)
+	if err != nil {
+		return nil, errAs(err)
+	}
+
+	// The session may already be owned by the user.
+	others := make([]string, 0, len(ids))
+	for _, i := range ids {
+		if i == id {
+			return nil, nil
+		}
+		others = append(others, i)
+	}
+
+	n := len(others) - m.opts.MaxSessionsPerUser + 1
+	if n <= 0 {
+		return nil, nil
+	}
+	if m.opts.SessionLimitPolicy == RejectNew {
+		return nil, ErrSessionLimit
+	}
+
+	return others[:n], nil
+}
+
+// evictUserSessions destroys the given sessions of the user and
+// calls `Options.OnEvict` with the ones that were destroyed.
+func (m *Manager) evictUserSessions(userID string, ids []string) error {
+	evicted := make([]string, 0, len(ids))
+	for _, id := range ids {
+		ok, err := m.destroy(id)
+		if err != nil {
+			return err
+		}
+		if ok {
+			evicted = append(evicted, id)
+		}
+	}
+
+	if len(evicted) > 0 && m.opts.OnEvict != nil {
+		m.opts.OnEvict(userID, evicted)
+	}
+
+	return nil
+}
+
+// destroy destroys the session with the given ID outside of a request, eg: to revoke it.
+// It returns false if the session doesn't exist.
+func (m *Manager) destroy(id string) (bool, error) {
+	start := time.Now()
+
+	err := errAs(m.storeCtx().DestroyCtx(context.Background(), id))
+	if err != nil {
+		if errors.Is(err, ErrInvalidSession) {
+			return false, nil
+		}
+		return false, err
+	}
+	m.emit(Event{Type: EventDestroy, ID: id, Time: start})
+
+	return true, nil
+}
+
+// userIndex returns the store as a UserIndexStoreContext if it implements
+// UserIndexStore. If the store doesn't implement the context aware methods,
+// it's wrapped and the context is ignored.
+func (m *Manager) userIndex() (UserIndexStoreContext, bool) {
+	u, ok := storeAs[UserIndexStore](m.store)
+	if !ok {
+		return nil, false
+	}
+
+	if c, ok := storeAs[UserIndexStoreContext](m.store); ok {
+		return c, true
+	}
+	return ctxUserIndex{u}, true
+}
+
+// hasTimeouts checks if idle or absolute timeouts are enabled.
+func (m *Manager) hasTimeouts() bool {
+	return m.opts.IdleTimeout > 0 || m.opts.AbsoluteTimeout > 0
+}
+
+// storeCtx returns the store as a StoreContext. If the store doesn't
+// implement it, it's wrapped and the context is ignored. Wrapped stores
+// aren't looked up as their methods would bypass the wrapper.
+func (m *Manager) storeCtx() StoreContext {
+	s, ok := m.store.(StoreContext)
+	if !ok {
+		s = ctxStore{m.store}
+	}
+
+	if m.log != nil {
+		return logStore{s, m}
+	}
+	return s
+}
+
+// isValidID validates the given session ID. If the store implements `IDValidator`,
+// it's used instead of the validator set on the Manager.
+func (m *Manager) isValidID(id string) bool {
+	if v, ok := storeAs[IDValidator](m.store); ok {
+		return v.IsValid(id)
+	}
+
+	return m.validateID(id)
 }
 
 // defaultGenerateID generates a random alpha-num session ID.
@@ -2,6 +2,7 @@ package simplesessions
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"testing"
@@ -10,7 +11,7 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-const mockSessionID = "sometestcookievalue"
+const mockSessionID = "sometestcookievalue0123456789abc"
 
 func newMockStore() *MockStore {
 	return &MockStore{
@@ -159,6 +160,53 @@ func TestManagerAcquireAutocreate(t *testing.T) {
 	assert.True(t, m.validateID(sess.id))
 }
 
+func TestManagerAcquireInvalidID(t *testing.T) {
+	m := newMockManager(newMockStore())
+	m.SetCookieHooks(func(name string, r interface{}) (*http.Cookie, error) {
+		return &http.Cookie{Name: name, Value: "invalid*id"}, nil
+	}, mockSetCookieCb)
+
+	// Invalid IDs are rejected without auto create.
+	sess, err := m.Acquire(context.Background(), nil, nil)
+	assert.ErrorIs(t, err, ErrInvalidSession)
+	assert.Nil(t, sess)
+
+	// A new session is created if auto create is enabled.
+	m.opts.EnableAutoCreate = true
+	sess, err = m.Acquire(context.Background(), nil, nil)
+	assert.NoError(t, err)
+	assert.NotEqual(t, "invalid*id", sess.ID())
+	assert.True(t, m.validateID(sess.ID()))
+}
+
+type mockValidatorStore struct {
+	*MockStore
+	valid bool
+}
+
+func (s *mockValidatorStore) IsValid(id string) bool {
+	return s.valid
+}
+
+func TestManagerAcquireStoreValidator(t *testing.T) {
+	str := &mockValidatorStore{MockStore: newMockStore()}
+	m := New(Options{})
+	m.UseStore(str)
+	m.SetCookieHooks(func(name string, r interface{}) (*http.Cookie, error) {
+		return &http.Cookie{Name: name, Value: "some-encoded-value"}, nil
+	}, mockSetCookieCb)
+
+	// Store's validator takes precedence over the Manager's.
+	str.valid = true
+	sess, err := m.Acquire(context.Background(), nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "some-encoded-value", sess.ID())
+
+	str.valid = false
+	_, err = m.Acquire(context.Background(), nil, nil)
+	assert.ErrorIs(t, err, ErrInvalidSession)
+}
+
 func TestManagerAcquireFromContext(t *testing.T) {
 	assert := assert.New(t)
 	m := newMockManager(newMockStore())
@@ -224,3 +272,364 @@ func TestSetSessionIDHooks(t *testing.T) {
 	valOut = false
 	assert.False(t, m.validateID(genID))
 }
+
+type ctxKey string
+
+// mockCtxStore records the context passed to the store.
+type mockCtxStore struct {
+	*MockStore
+	ctx context.Context
+}
+
+func (s *mockCtxStore) CreateCtx(ctx context.Context, id string) error {
+	s.ctx = ctx
+	return s.Create(id)
+}
+
+func (s *mockCtxStore) GetCtx(ctx context.Context, id, key string) (interface{}, error) {
+	s.ctx = ctx
+	return s.Get(id, key)
+}
+
+func (s *mockCtxStore) GetMultiCtx(ctx context.Context, id string, keys ...string) (map[string]interface{}, error) {
+	s.ctx = ctx
+	return s.GetMulti(id, keys...)
+}
+
+func (s *mockCtxStore) GetAllCtx(ctx context.Context, id string) (map[string]interface{}, error) {
+	s.ctx = ctx
+	return s.GetAll(id)
+}
+
+func (s *mockCtxStore) SetCtx(ctx context.Context, id, key string, value interface{}) error {
+	s.ctx = ctx
+	return s.Set(id, key, value)
+}
+
+func (s *mockCtxStore) SetMultiCtx(ctx context.Context, id string, data map[string]interface{}) error {
+	s.ctx = ctx
+	return s.SetMulti(id, data)
+}
+
+func (s *mockCtxStore) DeleteCtx(ctx context.Context, id string, key ...string) error {
+	s.ctx = ctx
+	return s.Delete(id, key...)
+}
+
+func (s *mockCtxStore) ClearCtx(ctx context.Context, id string) error {
+	s.ctx = ctx
+	return s.Clear(id)
+}
+
+func (s *mockCtxStore) DestroyCtx(ctx context.Context, id string) error {
+	s.ctx = ctx
+	return s.Destroy(id)
+}
+
+func TestManagerAcquireStoreContext(t *testing.T) {
+	str := &mockCtxStore{MockStore: newMockStore()}
+	m := New(Options{EnableAutoCreate: true})
+	m.UseStore(str)
+	m.SetCookieHooks(mockGetCookieCb, mockSetCookieCb)
+
+	ctx := context.WithValue(context.Background(), ctxKey("req"), "1")
+	sess, err := m.Acquire(ctx, nil, nil)
+	assert.NoError(t, err)
+
+	str.data = map[string]interface{}{}
+	assert.NoError(t, sess.Set("key1", 1))
+	assert.Equal(t, ctx, str.ctx)
+
+	str.ctx = nil
+	_, err = sess.Get("key1")
+	assert.NoError(t, err)
+	assert.Equal(t, ctx, str.ctx)
+
+	// Auto created sessions also get the context.
+	m.SetCookieHooks(func(string, interface{}) (*http.Cookie, error) { return nil, http.ErrNoCookie }, mockSetCookieCb)
+	str.ctx = nil
+	_, err = m.Acquire(ctx, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, ctx, str.ctx)
+
+	// Nil context defaults to background.
+	_, err = m.Acquire(nil, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, context.Background(), str.ctx)
+}
+
+func TestManagerAcquireTimeouts(t *testing.T) {
+	str := newMockStore()
+	m := newMockManager(str)
+	m.opts.AbsoluteTimeout = time.Hour
+	m.opts.IdleTimeout = time.Minute
+
+	// New sessions get the timestamps.
+	sess, err := m.NewSession(nil, nil)
+	assert.NoError(t, err)
+	assert.Contains(t, str.data, keyCreatedAt)
+	assert.Contains(t, str.data, keyLastSeen)
+
+	// Reserved keys are hidden.
+	assert.NoError(t, sess.Set("key1", 1))
+	all, err := sess.GetAll()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"key1": 1}, all)
+
+	// Valid session updates the last seen time once it's older than a tenth of the idle timeout.
+	seen := time.Now().Add(-time.Second * 3).Unix()
+	str.data[keyLastSeen] = seen
+	_, err = m.Acquire(context.Background(), nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, seen, str.data[keyLastSeen])
+
+	str.data[keyLastSeen] = time.Now().Add(-time.Second * 30).Unix()
+	_, err = m.Acquire(context.Background(), nil, nil)
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Now().Unix(), str.data[keyLastSeen], 1)
+
+	// Idle timeout.
+	str.data[keyLastSeen] = time.Now().Add(-time.Minute * 2).Unix()
+	_, err = m.Acquire(context.Background(), nil, nil)
+	assert.ErrorIs(t, err, ErrInvalidSession)
+	assert.Nil(t, str.data)
+
+	// Absolute timeout.
+	str.data = map[string]interface{}{
+		keyCreatedAt: time.Now().Add(-time.Hour * 2).Unix(),
+		keyLastSeen:  time.Now().Unix(),
+	}
+	_, err = m.Acquire(context.Background(), nil, nil)
+	assert.ErrorIs(t, err, ErrInvalidSession)
+	assert.Nil(t, str.data)
+
+	// Expired sessions are replaced if auto create is enabled.
+	m.opts.EnableAutoCreate = true
+	str.data = map[string]interface{}{
+		keyCreatedAt: time.Now().Add(-time.Hour * 2).Unix(),
+		keyLastSeen:  time.Now().Unix(),
+	}
+	sess, err = m.Acquire(context.Background(), nil, nil)
+	assert.NoError(t, err)
+	assert.NotEqual(t, mockSessionID, sess.ID())
+	assert.InDelta(t, time.Now().Unix(), str.data[keyCreatedAt], 1)
+
+	// Sessions without timestamps are initialized.
+	str.data = map[string]interface{}{}
+	_, err = m.Acquire(context.Background(), nil, nil)
+	assert.NoError(t, err)
+	assert.Contains(t, str.data, keyCreatedAt)
+	assert.Contains(t, str.data, keyLastSeen)
+
+	// Clear retains the creation time.
+	created := time.Now().Add(-time.Minute * 10).Unix()
+	str.data[keyCreatedAt] = created
+	assert.NoError(t, sess.Clear())
+	assert.Equal(t, created, str.data[keyCreatedAt])
+	assert.Contains(t, str.data, keyLastSeen)
+
+	// Timestamps that can't be read expire the session instead of being reset.
+	m.opts.EnableAutoCreate = false
+	for _, key := range []string{keyCreatedAt, keyLastSeen} {
+		str.data = map[string]interface{}{
+			keyCreatedAt: time.Now().Unix(),
+			keyLastSeen:  time.Now().Unix(),
+		}
+		str.data[key] = "tampered"
+		_, err = m.Acquire(context.Background(), nil, nil)
+		assert.ErrorIs(t, err, ErrInvalidSession)
+		assert.Nil(t, str.data)
+	}
+}
+
+type mockUserIndexStore struct {
+	*MockStore
+	owners    map[string]string
+	order     []string
+	destroyed []string
+}
+
+func newMockUserIndexStore() *mockUserIndexStore {
+	return &mockUserIndexStore{MockStore: newMockStore(), owners: map[string]string{}}
+}
+
+// own sets the owner of the session without checking if it exists.
+func (s *mockUserIndexStore) own(id, userID string) {
+	if s.owners[id] == userID {
+		return
+	}
+	s.unown(id)
+	s.owners[id] = userID
+	s.order = append(s.order, id)
+}
+
+func (s *mockUserIndexStore) unown(id string) {
+	delete(s.owners, id)
+	for i, o := range s.order {
+		if o == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func (s *mockUserIndexStore) SetOwner(id, userID string) error {
+	if s.id == "" || s.data == nil {
+		return ErrInvalidSession
+	}
+	s.own(id, userID)
+	return nil
+}
+
+func (s *mockUserIndexStore) UserSessions(userID string) ([]string, error) {
+	var out []string
+	for _, id := range s.order {
+		if s.owners[id] == userID {
+			out = append(out, id)
+		}
+	}
+	return out, s.err
+}
+
+func (s *mockUserIndexStore) Destroy(id string) error {
+	if _, ok := s.owners[id]; !ok {
+		return ErrInvalidSession
+	}
+	s.unown(id)
+	s.destroyed = append(s.destroyed, id)
+	return nil
+}
+
+func TestManagerUserSessions(t *testing.T) {
+	str := newMockUserIndexStore()
+	m := New(Options{})
+	m.UseStore(str)
+	m.SetCookieHooks(mockGetCookieCb, mockSetCookieCb)
+
+	sess, err := m.Acquire(context.Background(), nil, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, sess.SetOwner("user1"))
+	assert.Equal(t, "user1", str.owners[mockSessionID])
+
+	str.own("other1", "user1")
+	str.own("other2", "user1")
+	str.own("other3", "user2")
+
+	ids, err := m.ListUserSessions("user1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{mockSessionID, "other1", "other2"}, ids)
+
+	// Revoke all sessions of the user except the current one.
+	assert.NoError(t, m.RevokeUserSessions("user1", sess.ID()))
+	assert.Equal(t, []string{"other1", "other2"}, str.destroyed)
+
+	ids, err = m.ListUserSessions("user1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{mockSessionID}, ids)
+
+	ids, err = m.ListUserSessions("user2")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"other3"}, ids)
+
+	// Store errors.
+	str.err = errors.New("store error")
+	assert.ErrorIs(t, m.RevokeUserSessions("user2"), str.err)
+
+	// Stores without the index.
+	m.UseStore(newMockStore())
+	assert.ErrorIs(t, sess.SetOwner("user1"), ErrUserIndexUnsupported)
+	_, err = m.ListUserSessions("user1")
+	assert.ErrorIs(t, err, ErrUserIndexUnsupported)
+	assert.ErrorIs(t, m.RevokeUserSessions("user1"), ErrUserIndexUnsupported)
+}
+
+type mockUserIndexCtxStore struct {
+	*mockUserIndexStore
+	ctxs []context.Context
+}
+
+func (s *mockUserIndexCtxStore) SetOwnerCtx(ctx context.Context, id, userID string) error {
+	s.ctxs = append(s.ctxs, ctx)
+	return s.SetOwner(id, userID)
+}
+
+func (s *mockUserIndexCtxStore) UserSessionsCtx(ctx context.Context, userID string) ([]string, error) {
+	s.ctxs = append(s.ctxs, ctx)
+	return s.UserSessions(userID)
+}
+
+func TestSetOwnerContext(t *testing.T) {
+	var (
+		str = &mockUserIndexCtxStore{mockUserIndexStore: newMockUserIndexStore()}
+		m   = New(Options{MaxSessionsPerUser: 2})
+		ctx = context.WithValue(context.Background(), ctxNameType("test"), "value")
+	)
+	m.UseStore(str)
+	m.SetCookieHooks(mockGetCookieCb, mockSetCookieCb)
+
+	sess, err := m.Acquire(ctx, nil, nil)
+	assert.NoError(t, err)
+
+	// The session's context is passed to the store to check the limit and set the owner.
+	assert.NoError(t, sess.SetOwner("user1"))
+	assert.Equal(t, []context.Context{ctx, ctx}, str.ctxs)
+
+	// The Manager methods have no request.
+	str.ctxs = nil
+	_, err = m.ListUserSessions("user1")
+	assert.NoError(t, err)
+	assert.Equal(t, []context.Context{context.Background()}, str.ctxs)
+}
+
+func TestManagerMaxSessionsPerUser(t *testing.T) {
+	var (
+		str     = newMockUserIndexStore()
+		evicted []string
+	)
+	m := New(Options{
+		MaxSessionsPerUser: 2,
+		OnEvict: func(userID string, ids []string) {
+			assert.Equal(t, "user1", userID)
+			evicted = append(evicted, ids...)
+		},
+	})
+	m.UseStore(str)
+	m.SetCookieHooks(mockGetCookieCb, mockSetCookieCb)
+
+	sess, err := m.Acquire(context.Background(), nil, nil)
+	assert.NoError(t, err)
+
+	// Within the limit.
+	str.own("old1", "user1")
+	assert.NoError(t, sess.SetOwner("user1"))
+	assert.Empty(t, str.destroyed)
+	assert.Nil(t, evicted)
+
+	// Setting the same owner again doesn't count the session twice.
+	assert.NoError(t, sess.SetOwner("user1"))
+	assert.Empty(t, str.destroyed)
+
+	// The oldest sessions are evicted.
+	assert.NoError(t, sess.SetOwner(""))
+	str.own("old2", "user1")
+	str.own("old3", "user1")
+	str.own("other", "user2")
+	assert.NoError(t, sess.SetOwner("user1"))
+	assert.Equal(t, []string{"old1", "old2"}, str.destroyed)
+	assert.Equal(t, []string{"old1", "old2"}, evicted)
+
+	ids, err := m.ListUserSessions("user1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"old3", mockSessionID}, ids)
+
+	// New sessions are rejected.
+	m.opts.SessionLimitPolicy = RejectNew
+	assert.NoError(t, sess.SetOwner(""))
+	str.own("old4", "user1")
+	assert.ErrorIs(t, sess.SetOwner("user1"), ErrSessionLimit)
+	assert.Empty(t, str.owners[mockSessionID])
+	assert.Len(t, str.destroyed, 2)
+
+	// Removing the owner isn't limited.
+	assert.NoError(t, sess.SetOwner(""))
+}
@@ -0,0 +1,137 @@
+// Package nethttp provides ready-made cookie hooks and a middleware
+// for using simplesessions with net/http handlers.
+package nethttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/zerodha/simplesessions/v3"
+)
+
+var (
+	// ErrInvalidReader is returned by GetCookie if the reader isn't *http.Request.
+	ErrInvalidReader = errors.New("nethttp: reader is not *http.Request")
+
+	// ErrInvalidWriter is returned by SetCookie if the writer isn't http.ResponseWriter.
+	ErrInvalidWriter = errors.New("nethttp: writer is not http.ResponseWriter")
+)
+
+// GetCookie is a get cookie hook for `Manager.SetCookieHooks()`.
+// r should be the *http.Request passed to `Manager.Acquire()`.
+// http.ErrNoCookie is returned if the cookie doesn't exist.
+func GetCookie(name string, r interface{}) (*http.Cookie, error) {
+	rd, ok := r.(*http.Request)
+	if !ok {
+		return nil, ErrInvalidReader
+	}
+
+	return rd.Cookie(name)
+}
+
+// SetCookie is a set cookie hook for `Manager.SetCookieHooks()`.
+// w should be the http.ResponseWriter passed to `Manager.Acquire()`.
+func SetCookie(cookie *http.Cookie, w interface{}) error {
+	wr, ok := w.(http.ResponseWriter)
+	if !ok {
+		return ErrInvalidWriter
+	}
+
+	http.SetCookie(wr, cookie)
+	return nil
+}
+
+// Middleware returns a net/http middleware that acquires the session for every
+// request and stores it in the request context under `simplesessions.ContextName`.
+// Subsequent `Manager.Acquire()` calls with the request context return the same
+// session, and `FromContext()` can be used to retrieve it in handlers.
+//
+// If there's no session and `Options.EnableAutoCreate` is disabled, the request
+// is passed on without a session. Any other error responds with 500.
+//
+// If `Options.EnableBufferedWrites` is set, the session is committed before the
+// response headers are written, or after the handler returns if it doesn't write
+// a response. If the commit fails, the response is replaced with a 500.
+func Middleware(m *simplesessions.Manager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sess, err := m.Acquire(r.Context(), r, w)
+			if err != nil {
+				if errors.Is(err, simplesessions.ErrInvalidSession) {
+					next.ServeHTTP(w, r)
+					return
+				}
+
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), simplesessions.ContextName, sess)
+			if !sess.Buffered() {
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			cw := &commitWriter{ResponseWriter: w, sess: sess}
+			next.ServeHTTP(cw, r.WithContext(ctx))
+			cw.commit()
+		})
+	}
+}
+
+// commitWriter commits the session's buffered writes before the response
+// headers are written.
+type commitWriter struct {
+	http.ResponseWriter
+	sess *simplesessions.Session
+
+	done bool
+	err  error
+}
+
+// commit commits the session once. It returns false if the commit failed,
+// in which case a 500 has been written instead of the handler's response.
+func (w *commitWriter) commit() bool {
+	if !w.done {
+		w.done = true
+		if w.err = w.sess.Commit(); w.err != nil {
+			http.Error(w.ResponseWriter, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	}
+
+	return w.err == nil
+}
+
+func (w *commitWriter) WriteHeader(code int) {
+	if w.commit() {
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *commitWriter) Write(b []byte) (int, error) {
+	if !w.commit() {
+		return 0, w.err
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher.
+func (w *commitWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok && w.commit() {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying http.ResponseWriter for http.ResponseController.
+func (w *commitWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// FromContext returns the session stored in the context by `Middleware()`.
+// nil is returned if there's no session in the context.
+func FromContext(ctx context.Context) *simplesessions.Session {
+	sess, _ := ctx.Value(simplesessions.ContextName).(*simplesessions.Session)
+	return sess
+}
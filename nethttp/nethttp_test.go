@@ -0,0 +1,208 @@
+package nethttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zerodha/simplesessions/v3"
+)
+
+// mockStore is a minimal map based store for testing.
+type mockStore struct {
+	simplesessions.Store
+	mu       sync.Mutex
+	sessions map[string]map[string]interface{}
+	err      error
+}
+
+func newMockStore() *mockStore {
+	return &mockStore{sessions: map[string]map[string]interface{}{}}
+}
+
+func (s *mockStore) Create(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[id] = map[string]interface{}{}
+	return nil
+}
+
+func (s *mockStore) Get(id, key string) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil, simplesessions.ErrInvalidSession
+	}
+	return sess[key], nil
+}
+
+func (s *mockStore) Set(id, key string, val interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return simplesessions.ErrInvalidSession
+	}
+	sess[key] = val
+	return nil
+}
+
+func (s *mockStore) SetMulti(id string, data map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	sess, ok := s.sessions[id]
+	if !ok {
+		return simplesessions.ErrInvalidSession
+	}
+	for k, v := range data {
+		sess[k] = v
+	}
+	return nil
+}
+
+func newManager(str simplesessions.Store, autoCreate bool) *simplesessions.Manager {
+	m := simplesessions.New(simplesessions.Options{EnableAutoCreate: autoCreate})
+	m.UseStore(str)
+	m.SetCookieHooks(GetCookie, SetCookie)
+	return m
+}
+
+func TestCookieHooks(t *testing.T) {
+	_, err := GetCookie("session", nil)
+	assert.ErrorIs(t, err, ErrInvalidReader)
+	assert.ErrorIs(t, SetCookie(&http.Cookie{}, nil), ErrInvalidWriter)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	_, err = GetCookie("session", r)
+	assert.ErrorIs(t, err, http.ErrNoCookie)
+
+	r.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+	ck, err := GetCookie("session", r)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", ck.Value)
+
+	w := httptest.NewRecorder()
+	assert.NoError(t, SetCookie(&http.Cookie{Name: "session", Value: "xyz", HttpOnly: true}, w))
+	assert.Equal(t, "session=xyz; HttpOnly", w.Header().Get("Set-Cookie"))
+}
+
+func TestMiddleware(t *testing.T) {
+	var (
+		str = newMockStore()
+		m   = newManager(str, true)
+	)
+
+	var (
+		sess  *simplesessions.Session
+		calls int
+	)
+	h := Middleware(m)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		sess = FromContext(r.Context())
+		assert.NotNil(t, sess)
+
+		// Acquire with the request context returns the same session.
+		s, err := m.Acquire(r.Context(), r, w)
+		assert.NoError(t, err)
+		assert.Equal(t, sess, s)
+		assert.NoError(t, sess.Set("foo", "bar"))
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, 1, calls)
+	assert.Contains(t, w.Header().Get("Set-Cookie"), "session="+sess.ID())
+	assert.Equal(t, "bar", str.sessions[sess.ID()]["foo"])
+
+	// The existing session is acquired from the cookie.
+	id := sess.ID()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: id})
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, id, sess.ID())
+}
+
+func TestMiddlewareBuffered(t *testing.T) {
+	str := newMockStore()
+	m := simplesessions.New(simplesessions.Options{EnableAutoCreate: true, EnableBufferedWrites: true})
+	m.UseStore(str)
+	m.SetCookieHooks(GetCookie, SetCookie)
+
+	var sess *simplesessions.Session
+	h := Middleware(m)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sess = FromContext(r.Context())
+		assert.NoError(t, sess.Set("foo", "bar"))
+		assert.Nil(t, str.sessions[sess.ID()]["foo"])
+
+		// The session is committed before the response is written.
+		w.WriteHeader(http.StatusCreated)
+		assert.Equal(t, "bar", str.sessions[sess.ID()]["foo"])
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	// The session is committed after the handler if it doesn't write a response.
+	h = Middleware(m)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sess = FromContext(r.Context())
+		assert.NoError(t, sess.Set("foo", "baz"))
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "baz", str.sessions[sess.ID()]["foo"])
+
+	// Commit errors respond with 500.
+	h = Middleware(m)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, FromContext(r.Context()).Set("foo", "bar"))
+		str.err = errors.New("store error")
+
+		_, err := w.Write([]byte("ok"))
+		assert.ErrorIs(t, err, str.err)
+	}))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), "ok")
+}
+
+func TestMiddlewareNoSession(t *testing.T) {
+	m := newManager(newMockStore(), false)
+
+	var called bool
+	h := Middleware(m)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		assert.Nil(t, FromContext(r.Context()))
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.True(t, called)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestMiddlewareError(t *testing.T) {
+	// No store hooks set on the manager.
+	m := simplesessions.New(simplesessions.Options{})
+	m.UseStore(newMockStore())
+
+	h := Middleware(m)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler shouldn't be called")
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Nil(t, FromContext(context.Background()))
+}
@@ -0,0 +1,212 @@
+package simplesessions
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrInvalidStruct is returned by Scan() if the given value isn't a non-nil
+// pointer to a struct and by SetStruct() if it isn't a struct or a pointer to one.
+var ErrInvalidStruct = errors.New("simplesession: invalid struct")
+
+// FieldError is returned by Scan() when a struct field can't be set from the session.
+// Err is ErrNil if the key doesn't exist in the session and ErrAssertType if its value
+// can't be converted to the type of the field.
+type FieldError struct {
+	// Field is the name of the struct field.
+	Field string
+
+	// Key is the session key of the field.
+	Key string
+
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("simplesession: field %s (key %q): %v", e.Field, e.Key, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// structField is a struct field tagged with the session key.
+type structField struct {
+	index    int
+	name     string
+	key      string
+	optional bool
+}
+
+// Types that are converted with the store helpers.
+var (
+	typeInt     = reflect.TypeOf(int(0))
+	typeInt64   = reflect.TypeOf(int64(0))
+	typeUInt64  = reflect.TypeOf(uint64(0))
+	typeFloat64 = reflect.TypeOf(float64(0))
+	typeString  = reflect.TypeOf("")
+	typeBytes   = reflect.TypeOf([]byte(nil))
+	typeBool    = reflect.TypeOf(false)
+)
+
+// Scan gets the values of the keys in the struct tags of dst from the session
+// with a single GetMulti() and sets them to the fields. dst should be a pointer
+// to a struct. Only exported fields with the `session` tag are set.
+//
+//	type User struct {
+//		ID    int    `session:"user_id"`
+//		Name  string `session:"name"`
+//		Theme string `session:"theme,optional"`
+//	}
+//
+// Values are converted with the store's helpers, eg: Int(), for the types they
+// support and with GetAs() conversions for the rest or if the helper fails.
+// If a key doesn't exist, a *FieldError wrapping ErrNil is returned, unless the
+// field is marked optional, in which case it's left untouched. If a value can't
+// be converted, a *FieldError wrapping ErrAssertType is returned.
+func (s *Session) Scan(dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return ErrInvalidStruct
+	}
+	rv = rv.Elem()
+
+	fields := structFields(rv.Type())
+	if len(fields) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(fields))
+	for i, f := range fields {
+		keys[i] = f.key
+	}
+
+	vals, err := s.GetMulti(keys...)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		v := vals[f.key]
+		if v == nil {
+			if f.optional {
+				continue
+			}
+			return &FieldError{Field: f.name, Key: f.key, Err: ErrNil}
+		}
+
+		if err := s.scanValue(rv.Field(f.index), v); err != nil {
+			return &FieldError{Field: f.name, Key: f.key, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// SetStruct sets the fields of src that have the `session` tag to the session with
+// a single SetMulti(). src should be a struct or a pointer to one. Values are set
+// as they would be with SetAs(), ie: primitives as their basic types and the rest
+// encoded with the codec. See Scan() for the tags.
+func (s *Session) SetStruct(src interface{}) error {
+	rv := reflect.ValueOf(src)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return ErrInvalidStruct
+	}
+
+	fields := structFields(rv.Type())
+	if len(fields) == 0 {
+		return nil
+	}
+
+	data := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		v, err := encodeValue(rv.Field(f.index), s.manager.opts.Codec)
+		if err != nil {
+			return &FieldError{Field: f.name, Key: f.key, Err: err}
+		}
+		data[f.key] = v
+	}
+
+	return s.SetMulti(data)
+}
+
+// scanValue converts the value with the store helper for the type of dst, if there's
+// one, and sets it. Otherwise, or if the helper fails, GetAs() conversions are used.
+func (s *Session) scanValue(dst reflect.Value, v interface{}) error {
+	var (
+		out interface{}
+		err error
+	)
+	switch dst.Type() {
+	case typeInt:
+		out, err = s.Int(v, nil)
+	case typeInt64:
+		out, err = s.Int64(v, nil)
+	case typeUInt64:
+		out, err = s.UInt64(v, nil)
+	case typeFloat64:
+		out, err = s.Float64(v, nil)
+	case typeString:
+		out, err = s.String(v, nil)
+	case typeBytes:
+		out, err = s.Bytes(v, nil)
+	case typeBool:
+		out, err = s.Bool(v, nil)
+	default:
+		return convertValue(dst, v, s.manager.opts.Codec)
+	}
+
+	// Fall back to the generic conversions for values the helper doesn't support,
+	// eg: an int64 value for an int field in the in-memory store.
+	if errors.Is(err, ErrAssertType) {
+		return convertValue(dst, v, s.manager.opts.Codec)
+	}
+	if err != nil {
+		return err
+	}
+
+	dst.Set(reflect.ValueOf(out))
+	return nil
+}
+
+// structFields returns the exported fields of the struct type with the `session` tag.
+// The tag is the session key followed by optional flags, eg: `session:"theme,optional"`.
+// Fields with the tag "-" are skipped.
+func structFields(t reflect.Type) []structField {
+	var out []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		tag, ok := f.Tag.Lookup("session")
+		if !ok || tag == "-" {
+			continue
+		}
+
+		parts := strings.Split(tag, ",")
+		sf := structField{
+			index: i,
+			name:  f.Name,
+			key:   parts[0],
+		}
+		if sf.key == "" {
+			sf.key = f.Name
+		}
+		for _, p := range parts[1:] {
+			if p == "optional" {
+				sf.optional = true
+			}
+		}
+
+		out = append(out, sf)
+	}
+
+	return out
+}
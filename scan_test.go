@@ -0,0 +1,113 @@
+package simplesessions
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testProfile struct {
+	ID       int      `session:"user_id"`
+	Name     string   `session:"name"`
+	Score    float64  `session:"score"`
+	Active   bool     `session:"active"`
+	Role     testRole `session:"role"`
+	User     testUser `session:"user"`
+	Theme    string   `session:"theme,optional"`
+	Default  int      `session:",optional"`
+	Skip     string   `session:"-"`
+	Untagged string
+	private  string `session:"private"`
+}
+
+func TestSetStructScan(t *testing.T) {
+	str := newMockStore()
+	mgr := newMockManager(str)
+	sess, err := mgr.NewSession(nil, nil)
+	assert.NoError(t, err)
+
+	in := testProfile{
+		ID:       10,
+		Name:     "foo",
+		Score:    1.5,
+		Active:   true,
+		Role:     testRole("admin"),
+		User:     testUser{Name: "foo", Age: 30, Roles: []string{"admin"}},
+		Theme:    "dark",
+		Default:  5,
+		Skip:     "skip",
+		Untagged: "untagged",
+		private:  "private",
+	}
+	assert.NoError(t, sess.SetStruct(&in))
+	assert.Len(t, str.data, 8)
+	assert.Equal(t, 10, str.data["user_id"])
+	assert.Equal(t, "admin", str.data["role"])
+	assert.Equal(t, `{"name":"foo","age":30,"roles":["admin"]}`, str.data["user"])
+	assert.Equal(t, 5, str.data["Default"])
+
+	var out testProfile
+	assert.NoError(t, sess.Scan(&out))
+	in.Skip, in.Untagged, in.private = "", "", ""
+	assert.Equal(t, in, out)
+
+	// Structs are accepted by value.
+	assert.NoError(t, sess.SetStruct(testProfile{ID: 20}))
+	assert.Equal(t, 20, str.data["user_id"])
+}
+
+func TestScan(t *testing.T) {
+	str := newMockStore()
+	mgr := newMockManager(str)
+	sess, err := mgr.NewSession(nil, nil)
+	assert.NoError(t, err)
+
+	type user struct {
+		ID    int    `session:"user_id"`
+		Count int64  `session:"count"`
+		Name  string `session:"name"`
+		Theme string `session:"theme,optional"`
+	}
+
+	// Values not supported by the store helpers are converted.
+	str.data = map[string]interface{}{
+		"user_id": float64(10),
+		"count":   "20",
+		"name":    []byte("foo"),
+	}
+
+	out := user{Theme: "light"}
+	assert.NoError(t, sess.Scan(&out))
+	assert.Equal(t, user{ID: 10, Count: 20, Name: "foo", Theme: "light"}, out)
+
+	// Missing required fields.
+	delete(str.data, "name")
+	err = sess.Scan(&out)
+	assert.ErrorIs(t, err, ErrNil)
+
+	var fErr *FieldError
+	assert.True(t, errors.As(err, &fErr))
+	assert.Equal(t, "Name", fErr.Field)
+	assert.Equal(t, "name", fErr.Key)
+
+	// Values that can't be converted.
+	str.data["name"] = "foo"
+	str.data["user_id"] = 1.5
+	err = sess.Scan(&out)
+	assert.ErrorIs(t, err, ErrAssertType)
+	assert.True(t, errors.As(err, &fErr))
+	assert.Equal(t, "ID", fErr.Field)
+
+	// Invalid targets.
+	assert.ErrorIs(t, sess.Scan(out), ErrInvalidStruct)
+	assert.ErrorIs(t, sess.Scan((*user)(nil)), ErrInvalidStruct)
+	assert.ErrorIs(t, sess.Scan(new(int)), ErrInvalidStruct)
+	assert.ErrorIs(t, sess.SetStruct(10), ErrInvalidStruct)
+	assert.ErrorIs(t, sess.SetStruct((*user)(nil)), ErrInvalidStruct)
+
+	// Store errors.
+	str.data["user_id"] = 10
+	str.err = errors.New("store error")
+	assert.ErrorIs(t, sess.Scan(&out), str.err)
+}
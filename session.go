@@ -1,8 +1,11 @@
 package simplesessions
 
 import (
+	"context"
 	"errors"
+	"log/slog"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 )
@@ -21,6 +24,24 @@ type Session struct {
 	// Session ID.
 	id string
 
+	// Writes buffered until `Commit` when `EnableBufferedWrites` is set.
+	// A key is either in sets or in dels, never both.
+	sets   map[string]interface{}
+	dels   map[string]struct{}
+	bufMux sync.Mutex
+
+	// Data of client-side stores that implement `Flusher` with the writes applied.
+	// nil until it's loaded from the store by the first write.
+	data    map[string]interface{}
+	dataMux sync.Mutex
+
+	// Error returned by the store for the last failed operation. See `StoreErr()`.
+	storeErr    error
+	storeErrMux sync.Mutex
+
+	// Context passed to the stores that implement `StoreContext`.
+	ctx context.Context
+
 	// HTTP reader and writer interfaces which are passed on to `GetCookie`` and `SetCookie`` callbacks.
 	reader interface{}
 	writer interface{}
@@ -29,16 +50,37 @@ type Session struct {
 var (
 	// ErrInvalidSession is raised when session is tried to access before setting it or its not set in store.
 	// Handle this and create new session.
-	// Store code = 1
+	// Store code = CodeInvalidSession
 	ErrInvalidSession = errors.New("simplesession: invalid session")
 
 	// ErrNil is raised when returned value is nil.
-	// Store code = 2
+	// Store code = CodeNil
 	ErrNil = errors.New("simplesession: nil returned")
 
 	// ErrAssertType is raised when type assertion fails
-	// Store code = 3
+	// Store code = CodeAssertType
 	ErrAssertType = errors.New("simplesession: invalid type assertion")
+
+	// ErrUserIndexUnsupported is raised when sessions are indexed by their owner
+	// but the store doesn't implement `UserIndexStore`.
+	ErrUserIndexUnsupported = errors.New("simplesession: store doesn't support user index")
+
+	// ErrSessionLimit is raised when a user exceeds `Options.MaxSessionsPerUser`
+	// and `Options.SessionLimitPolicy` is RejectNew.
+	ErrSessionLimit = errors.New("simplesession: session limit reached")
+)
+
+const (
+	// Reserved session keys that hold the metadata for enforcing timeouts.
+	// Values are unix timestamps in seconds.
+	keyCreatedAt = "_ss_created_at"
+	keyLastSeen  = "_ss_last_seen"
+
+	// Prefix of reserved session keys. These are omitted from GetAll() results.
+	reservedPrefix = "_ss_"
+
+	// The last seen time is updated once it's older than IdleTimeout / lastSeenFraction.
+	lastSeenFraction = 10
 )
 
 type errCode interface {
@@ -61,7 +103,7 @@ func (s *Session) WriteCookie(id string) error {
 	}
 
 	// Call `SetCookie` callback to write cookie to response
-	return s.manager.setCookieHook(ck, s.writer)
+	return s.writeCookie(ck)
 }
 
 // ClearCookie sets the cookie's expiry to one day prior to clear it.
@@ -74,7 +116,27 @@ func (s *Session) ClearCookie() error {
 	}
 
 	// Call `SetCookie` callback to write cookie to response
-	return s.manager.setCookieHook(ck, s.writer)
+	return s.writeCookie(ck)
+}
+
+// writeCookie writes the cookie with the `SetCookie` callback.
+func (s *Session) writeCookie(ck *http.Cookie) error {
+	start := time.Now()
+	if err := s.manager.setCookieHook(ck, s.writer); err != nil {
+		if l := s.manager.log; l != nil {
+			l.ErrorContext(s.ctx, "error writing cookie", slog.String("session_id", RedactID(s.id)), slog.Any("error", err))
+		}
+		return err
+	}
+	s.emit(EventWriteCookie, start)
+
+	msg := "cookie written"
+	if ck.Value == "" {
+		msg = "cookie cleared"
+	}
+	s.manager.debug(s.ctx, msg, s.id, slog.String("cookie", ck.Name))
+
+	return nil
 }
 
 // ID returns the acquired session ID. If cookie is not set then empty string is returned.
@@ -157,16 +219,19 @@ func (s *Session) deleteCache(key ...string) {
 // Subsequent Get/GetMulti calls return cached values, avoiding store access.
 // Use ResetCache() to ensure GetAll/Get/GetMulti fetches from the store.
 func (s *Session) Cache() error {
-	all, err := s.manager.store.GetAll(s.id)
+	all, err := s.store().GetAllCtx(s.ctx, s.id)
 	if err != nil {
 		return err
 	}
+	all = s.applyBuffer(all, nil)
 
 	s.cacheMux.Lock()
 	defer s.cacheMux.Unlock()
 	s.cache = map[string]interface{}{}
 	for k, v := range all {
-		s.cache[k] = v
+		if !strings.HasPrefix(k, reservedPrefix) {
+			s.cache[k] = v
+		}
 	}
 
 	return nil
@@ -188,8 +253,12 @@ func (s *Session) GetAll() (map[string]interface{}, error) {
 	}
 
 	// Get the values from store.
-	out, err := s.manager.store.GetAll(s.id)
-	return out, errAs(err)
+	out, err := s.store().GetAllCtx(s.ctx, s.id)
+	if err != nil {
+		return nil, s.errAs(err)
+	}
+
+	return s.applyBuffer(omitReserved(out), nil), nil
 }
 
 // GetMulti retrieves values for multiple session fields.
@@ -201,8 +270,12 @@ func (s *Session) GetMulti(key ...string) (map[string]interface{}, error) {
 		return c, nil
 	}
 
-	out, err := s.manager.store.GetMulti(s.id, key...)
-	return out, errAs(err)
+	out, err := s.store().GetMultiCtx(s.ctx, s.id, key...)
+	if err != nil {
+		return out, s.errAs(err)
+	}
+
+	return s.applyBuffer(out, key), nil
 }
 
 // Get retrieves a value for the given key in the session.
@@ -216,108 +289,554 @@ func (s *Session) Get(key string) (interface{}, error) {
 		return c[key], nil
 	}
 
+	// Try to get the value from buffered writes.
+	if v, ok := s.getBuffer(key); ok {
+		return v, nil
+	}
+
 	// Fetch from store if not found in the map.
-	out, err := s.manager.store.Get(s.id, key)
-	return out, errAs(err)
+	out, err := s.store().GetCtx(s.ctx, s.id, key)
+	return out, s.errAs(err)
 }
 
 // Set assigns a value to the given key in the session.
 func (s *Session) Set(key string, val interface{}) error {
-	err := s.manager.store.Set(s.id, key, val)
-	if err == nil {
-		s.setCache(map[string]interface{}{
-			key: val,
-		})
+	if s.Buffered() {
+		s.bufferSet(map[string]interface{}{key: val})
+		return nil
 	}
-	return errAs(err)
+
+	err := s.store().SetCtx(s.ctx, s.id, key, val)
+	if err != nil {
+		return s.errAs(err)
+	}
+	s.setCache(map[string]interface{}{
+		key: val,
+	})
+
+	return s.flush()
 }
 
 // SetMulti assigns multiple values to the session.
 func (s *Session) SetMulti(data map[string]interface{}) error {
-	err := s.manager.store.SetMulti(s.id, data)
-	if err == nil {
-		s.setCache(data)
+	if s.Buffered() {
+		s.bufferSet(data)
+		return nil
 	}
-	return errAs(err)
+
+	err := s.store().SetMultiCtx(s.ctx, s.id, data)
+	if err != nil {
+		return s.errAs(err)
+	}
+	s.setCache(data)
+
+	return s.flush()
 }
 
 // Delete deletes a given list of fields from the session.
 func (s *Session) Delete(key ...string) error {
-	err := s.manager.store.Delete(s.id, key...)
-	if err == nil {
-		s.deleteCache(key...)
+	if s.Buffered() {
+		s.bufferDelete(key...)
+		return nil
 	}
-	return errAs(err)
+
+	err := s.store().DeleteCtx(s.ctx, s.id, key...)
+	if err != nil {
+		return s.errAs(err)
+	}
+	s.deleteCache(key...)
+
+	return s.flush()
 }
 
 // Clear empties the data for the given session id but doesn't clear the cookie.
 // Use `Destroy()` to delete entire session from the store and clear the cookie.
 func (s *Session) Clear() error {
-	err := s.manager.store.Clear(s.id)
+	start := time.Now()
+
+	// Retain the creation time so that clearing doesn't extend the absolute timeout.
+	var meta map[string]interface{}
+	if s.manager.hasTimeouts() {
+		v, err := s.store().GetCtx(s.ctx, s.id, keyCreatedAt)
+		if err != nil {
+			return s.errAs(err)
+		}
+		meta = map[string]interface{}{keyCreatedAt: v, keyLastSeen: time.Now().Unix()}
+	}
+
+	err := s.store().ClearCtx(s.ctx, s.id)
 	if err != nil {
-		return errAs(err)
+		return s.errAs(err)
 	}
 	s.ResetCache()
+	s.resetBuffer()
+
+	if meta != nil {
+		if err := s.setMeta(meta); err != nil {
+			return err
+		}
+	}
+
+	if err := s.flush(); err != nil {
+		return err
+	}
+	s.emit(EventClear, start)
+
 	return nil
 }
 
 // Destroy deletes the session from backend and clears the cookie.
+// Sessions that no longer exist in the store, eg: expired ones, are
+// considered destroyed and their cookie is cleared.
 func (s *Session) Destroy() error {
-	err := s.manager.store.Destroy(s.id)
-	if err != nil {
-		return errAs(err)
+	start := time.Now()
+
+	err := s.errAs(s.store().DestroyCtx(s.ctx, s.id))
+	if err != nil && !errors.Is(err, ErrInvalidSession) {
+		return err
 	}
 	s.ResetCache()
+	s.resetBuffer()
+	if err == nil {
+		s.emit(EventDestroy, start)
+	}
+
 	return s.ClearCookie()
 }
 
+// Buffered returns true if `Options.EnableBufferedWrites` is set and the
+// writes on the session have to be flushed to the store with `Commit()`.
+func (s *Session) Buffered() bool {
+	return s.manager.opts.EnableBufferedWrites
+}
+
+// Commit flushes the buffered writes to the store with a single `SetMulti`
+// and `Delete` call. It does nothing if there are no buffered writes or
+// if `Options.EnableBufferedWrites` isn't set. If the store returns an error,
+// the writes remain buffered.
+func (s *Session) Commit() error {
+	s.bufMux.Lock()
+	defer s.bufMux.Unlock()
+
+	if len(s.sets) == 0 && len(s.dels) == 0 {
+		return nil
+	}
+
+	if len(s.sets) > 0 {
+		if err := s.store().SetMultiCtx(s.ctx, s.id, s.sets); err != nil {
+			return s.errAs(err)
+		}
+		s.sets = nil
+	}
+
+	if len(s.dels) > 0 {
+		keys := make([]string, 0, len(s.dels))
+		for k := range s.dels {
+			keys = append(keys, k)
+		}
+		if err := s.store().DeleteCtx(s.ctx, s.id, keys...); err != nil {
+			return s.errAs(err)
+		}
+		s.dels = nil
+	}
+
+	return s.flush()
+}
+
+// Regenerate generates a new session ID, moves the session data to it
+// and writes the new ID to the cookie. The old session is removed from
+// server-side stores. With client-side stores that implement `Flusher`,
+// eg: securecookie, the old cookie value remains valid until it expires.
+// This should be called on privilege changes, such as a login, to
+// prevent session fixation.
+func (s *Session) Regenerate() error {
+	start := time.Now()
+
+	id, err := s.manager.generateID()
+	if err != nil {
+		return errAs(err)
+	}
+
+	if err := s.rename(id); err != nil {
+		return s.errAs(err)
+	}
+	prev := s.id
+	s.id = id
+
+	if err := s.writeID(); err != nil {
+		return err
+	}
+	s.manager.emit(Event{
+		Type:   EventRegenerate,
+		ID:     s.id,
+		PrevID: prev,
+		Reader: s.reader,
+		Writer: s.writer,
+		Time:   start,
+	})
+
+	return nil
+}
+
+// SetOwner sets the owner of the session, usually the ID of the logged in user,
+// so that it's listed by `Manager.ListUserSessions()` and can be revoked with
+// `Manager.RevokeUserSessions()`. The owner is retained by `Regenerate()` and
+// `Clear()`. An empty userID removes the owner. ErrUserIndexUnsupported is
+// returned if the store doesn't implement `UserIndexStore`.
+//
+// If `Options.MaxSessionsPerUser` is set and the user already has as many sessions,
+// the oldest ones are destroyed or ErrSessionLimit is returned, as per
+// `Options.SessionLimitPolicy`.
+func (s *Session) SetOwner(userID string) error {
+	u, ok := s.manager.userIndex()
+	if !ok {
+		return ErrUserIndexUnsupported
+	}
+
+	var evict []string
+	if userID != "" && s.manager.opts.MaxSessionsPerUser > 0 {
+		ids, err := s.manager.limitUserSessions(s.ctx, u, s.id, userID)
+		if err != nil {
+			return err
+		}
+		evict = ids
+	}
+
+	if err := u.SetOwnerCtx(s.ctx, s.id, userID); err != nil {
+		s.manager.logErr(s.ctx, "set_owner", s.id, err)
+		return s.errAs(err)
+	}
+
+	if len(evict) > 0 {
+		return s.manager.evictUserSessions(userID, evict)
+	}
+
+	return nil
+}
+
+// rename moves the session data to the given ID in the store.
+func (s *Session) rename(id string) error {
+	// The data of client-side stores is held by the session and moves with it.
+	if f, ok := s.store().(flushStore); ok {
+		f.s.dataMux.Lock()
+		defer f.s.dataMux.Unlock()
+		return f.load(s.ctx, s.id)
+	}
+
+	if r, ok := storeAs[Renamer](s.manager.store); ok {
+		var err error
+		if c, ok := storeAs[RenamerContext](s.manager.store); ok {
+			err = c.RenameCtx(s.ctx, s.id, id)
+		} else {
+			err = r.Rename(s.id, id)
+		}
+		s.manager.logErr(s.ctx, "rename", s.id, err)
+		return err
+	}
+
+	// The store can't rename, copy the data to a new session instead.
+	str := s.store()
+	data, err := str.GetAllCtx(s.ctx, s.id)
+	if err != nil {
+		return err
+	}
+
+	if err := str.CreateCtx(s.ctx, id); err != nil {
+		return err
+	}
+
+	if len(data) > 0 {
+		if err := str.SetMultiCtx(s.ctx, id, data); err != nil {
+			return err
+		}
+	}
+
+	return str.DestroyCtx(s.ctx, s.id)
+}
+
+// checkExpiry checks the session against the idle and absolute timeouts.
+// Expired sessions are destroyed and ErrInvalidSession is returned.
+// Otherwise, the last seen time of the session is updated.
+func (s *Session) checkExpiry() error {
+	if !s.manager.hasTimeouts() {
+		return nil
+	}
+	start := time.Now()
+
+	vals, err := s.store().GetMultiCtx(s.ctx, s.id, keyCreatedAt, keyLastSeen)
+	if err != nil {
+		return s.errAs(err)
+	}
+
+	var (
+		opt = s.manager.opts
+		now = time.Now()
+	)
+	createdAt, errCreated := s.manager.store.Int64(vals[keyCreatedAt], nil)
+	lastSeen, errSeen := s.manager.store.Int64(vals[keyLastSeen], nil)
+
+	// Only missing timestamps are initialized. Timestamps that can't be read,
+	// eg: corrupted or tampered values, expire the session as resetting them
+	// would restart the timeouts.
+	if (errCreated != nil && vals[keyCreatedAt] != nil) ||
+		(errSeen != nil && vals[keyLastSeen] != nil) ||
+		(opt.AbsoluteTimeout > 0 && errCreated == nil && now.Sub(time.Unix(createdAt, 0)) > opt.AbsoluteTimeout) ||
+		(opt.IdleTimeout > 0 && errSeen == nil && now.Sub(time.Unix(lastSeen, 0)) > opt.IdleTimeout) {
+		err := s.store().DestroyCtx(s.ctx, s.id)
+		if err := s.errAs(err); err != nil && !errors.Is(err, ErrInvalidSession) {
+			return err
+		}
+		s.emit(EventExpire, start)
+		s.manager.debug(s.ctx, "session expired", s.id)
+		return ErrInvalidSession
+	}
+
+	// Update the last seen time once it's older than a fraction of the idle timeout
+	// instead of on every acquisition, which would be a write to the store, and a new
+	// cookie with client-side stores, on every request. Sessions created before the
+	// timeouts were enabled don't have the metadata and are initialized now.
+	meta := map[string]interface{}{}
+	if errCreated != nil {
+		meta[keyCreatedAt] = now.Unix()
+	}
+	if errSeen != nil || (opt.IdleTimeout > 0 && now.Sub(time.Unix(lastSeen, 0)) >= opt.IdleTimeout/lastSeenFraction) {
+		meta[keyLastSeen] = now.Unix()
+	}
+	if len(meta) == 0 {
+		return nil
+	}
+
+	if err := s.setMeta(meta); err != nil {
+		return err
+	}
+
+	return s.flush()
+}
+
+// store returns the store as a StoreContext. Writes to stores that implement
+// `Flusher` are applied to the session's own copy of the data.
+func (s *Session) store() StoreContext {
+	if _, ok := storeAs[Flusher](s.manager.store); ok {
+		return flushStore{s.manager.storeCtx(), s}
+	}
+
+	return s.manager.storeCtx()
+}
+
+// setMeta writes the given reserved metadata to the session in the store.
+func (s *Session) setMeta(meta map[string]interface{}) error {
+	return s.errAs(s.store().SetMultiCtx(s.ctx, s.id, meta))
+}
+
+// bufferSet buffers the given kv pairs until `Commit`.
+func (s *Session) bufferSet(data map[string]interface{}) {
+	s.bufMux.Lock()
+	if s.sets == nil {
+		s.sets = make(map[string]interface{}, len(data))
+	}
+	for k, v := range data {
+		s.sets[k] = v
+		delete(s.dels, k)
+	}
+	s.bufMux.Unlock()
+
+	s.setCache(data)
+}
+
+// bufferDelete buffers the deletion of the given keys until `Commit`.
+func (s *Session) bufferDelete(key ...string) {
+	s.bufMux.Lock()
+	if s.dels == nil {
+		s.dels = make(map[string]struct{}, len(key))
+	}
+	for _, k := range key {
+		s.dels[k] = struct{}{}
+		delete(s.sets, k)
+	}
+	s.bufMux.Unlock()
+
+	s.deleteCache(key...)
+}
+
+// getBuffer returns the buffered value for the given key. Deleted keys are returned as nil.
+// ok is false if there's no buffered write for the key.
+func (s *Session) getBuffer(key string) (interface{}, bool) {
+	s.bufMux.Lock()
+	defer s.bufMux.Unlock()
+
+	if v, ok := s.sets[key]; ok {
+		return v, true
+	}
+	if _, ok := s.dels[key]; ok {
+		return nil, true
+	}
+
+	return nil, false
+}
+
+// applyBuffer applies the buffered writes over the given values fetched from the store.
+// If keys are given, only those keys are applied and deleted keys are set to nil,
+// otherwise all buffered writes are applied and deleted keys are removed.
+func (s *Session) applyBuffer(data map[string]interface{}, keys []string) map[string]interface{} {
+	s.bufMux.Lock()
+	defer s.bufMux.Unlock()
+
+	if len(s.sets) == 0 && len(s.dels) == 0 {
+		return data
+	}
+
+	// Copy as stores may return their internal maps.
+	out := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		out[k] = v
+	}
+
+	if keys != nil {
+		for _, k := range keys {
+			if v, ok := s.sets[k]; ok {
+				out[k] = v
+			} else if _, ok := s.dels[k]; ok {
+				out[k] = nil
+			}
+		}
+		return out
+	}
+
+	for k, v := range s.sets {
+		out[k] = v
+	}
+	for k := range s.dels {
+		delete(out, k)
+	}
+
+	return out
+}
+
+// resetBuffer discards the buffered writes.
+func (s *Session) resetBuffer() {
+	s.bufMux.Lock()
+	s.sets = nil
+	s.dels = nil
+	s.bufMux.Unlock()
+}
+
+// flush flushes the writes in stores that implement `Flusher`. The encoded
+// value becomes the session ID and is written to the cookie.
+func (s *Session) flush() error {
+	f, ok := storeAs[Flusher](s.manager.store)
+	if !ok {
+		return nil
+	}
+
+	id, err := flushStore{s.manager.storeCtx(), s}.encode(s.ctx, f, s.id)
+	if err != nil {
+		s.manager.logErr(s.ctx, "flush", s.id, err)
+		return s.errAs(err)
+	}
+	s.id = id
+
+	return s.WriteCookie(id)
+}
+
+// writeID writes the session ID to the cookie. For stores that implement
+// `Flusher`, the session is flushed and the encoded value is written instead.
+func (s *Session) writeID() error {
+	if _, ok := storeAs[Flusher](s.manager.store); ok {
+		return s.flush()
+	}
+
+	return s.WriteCookie(s.id)
+}
+
+// omitReserved removes the reserved metadata keys from the given map.
+func omitReserved(data map[string]interface{}) map[string]interface{} {
+	for k := range data {
+		if strings.HasPrefix(k, reservedPrefix) {
+			out := make(map[string]interface{}, len(data))
+			for k, v := range data {
+				if !strings.HasPrefix(k, reservedPrefix) {
+					out[k] = v
+				}
+			}
+			return out
+		}
+	}
+
+	return data
+}
+
 // Int is a helper to get values as integer.
 // If the value is Nil, ErrNil is returned, which means key doesn't exist.
 func (s *Session) Int(r interface{}, err error) (int, error) {
 	out, err := s.manager.store.Int(r, err)
-	return out, errAs(err)
+	return out, s.errAs(err)
 }
 
 // Int64 is a helper to get values as Int64.
 // If the value is Nil, ErrNil is returned, which means key doesn't exist.
 func (s *Session) Int64(r interface{}, err error) (int64, error) {
 	out, err := s.manager.store.Int64(r, err)
-	return out, errAs(err)
+	return out, s.errAs(err)
 }
 
 // UInt64 is a helper to get values as UInt64.
 // If the value is Nil, ErrNil is returned, which means key doesn't exist.
 func (s *Session) UInt64(r interface{}, err error) (uint64, error) {
 	out, err := s.manager.store.UInt64(r, err)
-	return out, errAs(err)
+	return out, s.errAs(err)
 }
 
 // Float64 is a helper to get values as Float64.
 // If the value is Nil, ErrNil is returned, which means key doesn't exist.
 func (s *Session) Float64(r interface{}, err error) (float64, error) {
 	out, err := s.manager.store.Float64(r, err)
-	return out, errAs(err)
+	return out, s.errAs(err)
 }
 
 // String is a helper to get values as String.
 // If the value is Nil, ErrNil is returned, which means key doesn't exist.
 func (s *Session) String(r interface{}, err error) (string, error) {
 	out, err := s.manager.store.String(r, err)
-	return out, errAs(err)
+	return out, s.errAs(err)
 }
 
 // Bytes is a helper to get values as Bytes.
 // If the value is Nil, ErrNil is returned, which means key doesn't exist.
 func (s *Session) Bytes(r interface{}, err error) ([]byte, error) {
 	out, err := s.manager.store.Bytes(r, err)
-	return out, errAs(err)
+	return out, s.errAs(err)
 }
 
 // Bool is a helper to get values as Bool.
 // If the value is Nil, ErrNil is returned, which means key doesn't exist.
 func (s *Session) Bool(r interface{}, err error) (bool, error) {
 	out, err := s.manager.store.Bool(r, err)
-	return out, errAs(err)
+	return out, s.errAs(err)
+}
+
+// StoreErr returns the error returned by the store for the last failed operation
+// on the session, or nil. The Session methods return ErrInvalidSession, ErrNil and
+// ErrAssertType in place of the store's errors so that they can be compared with `==`.
+// The store's error retains the underlying cause, eg: a Redis or SQL error, which can
+// be inspected with errors.Unwrap(), errors.Is() and errors.As().
+func (s *Session) StoreErr() error {
+	s.storeErrMux.Lock()
+	defer s.storeErrMux.Unlock()
+
+	return s.storeErr
+}
+
+// errAs maps the error from the store with errAs() and records it for StoreErr().
+func (s *Session) errAs(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	s.storeErrMux.Lock()
+	s.storeErr = err
+	s.storeErrMux.Unlock()
+
+	return errAs(err)
 }
 
 // errAs takes an error coming from a store and maps it to an error
@@ -327,17 +846,17 @@ func errAs(err error) error {
 		return nil
 	}
 
-	e, ok := err.(errCode)
-	if !ok {
+	var e errCode
+	if !errors.As(err, &e) {
 		return err
 	}
 
 	switch e.Code() {
-	case 1:
+	case CodeInvalidSession:
 		return ErrInvalidSession
-	case 2:
+	case CodeNil:
 		return ErrNil
-	case 3:
+	case CodeAssertType:
 		return ErrAssertType
 	}
 
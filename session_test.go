@@ -1,6 +1,7 @@
 package simplesessions
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -39,6 +40,68 @@ func TestErrorTypes(t *testing.T) {
 	assert.Equal(t, errAs(errCustom), errCustom)
 }
 
+func TestStoreError(t *testing.T) {
+	var (
+		errInvalidSession = NewStoreError(CodeInvalidSession, "invalid session")
+		errNil            = NewStoreError(CodeNil, "nil returned")
+		errAssertType     = NewStoreError(CodeAssertType, "assertion failed")
+		errCause          = errors.New("connection refused")
+	)
+
+	assert.Equal(t, CodeInvalidSession, errInvalidSession.Code())
+	assert.Equal(t, "invalid session", errInvalidSession.Error())
+	assert.Nil(t, errInvalidSession.Unwrap())
+
+	// Errors match the package errors and errors with the same code.
+	assert.ErrorIs(t, errInvalidSession, ErrInvalidSession)
+	assert.ErrorIs(t, errNil, ErrNil)
+	assert.ErrorIs(t, errAssertType, ErrAssertType)
+	assert.ErrorIs(t, errInvalidSession, NewStoreError(CodeInvalidSession, "other"))
+	assert.NotErrorIs(t, errInvalidSession, ErrNil)
+	assert.NotErrorIs(t, errInvalidSession, errNil)
+
+	// Errors without a cause are mapped to the package errors.
+	assert.Equal(t, ErrInvalidSession, errAs(errInvalidSession))
+	assert.Equal(t, ErrNil, errAs(errNil))
+	assert.Equal(t, ErrAssertType, errAs(errAssertType))
+
+	// Errors with a cause are mapped to the package errors too.
+	err := errInvalidSession.Wrap(errCause)
+	assert.Equal(t, "invalid session: connection refused", err.Error())
+	assert.Equal(t, CodeInvalidSession, err.Code())
+	assert.ErrorIs(t, err, ErrInvalidSession)
+	assert.ErrorIs(t, err, errCause)
+	assert.Equal(t, ErrInvalidSession, errAs(err))
+
+	// Errors wrapped by the store are unwrapped.
+	assert.Equal(t, ErrNil, errAs(fmt.Errorf("wrapped: %w", &Err{code: CodeNil})))
+}
+
+func TestStoreErr(t *testing.T) {
+	var (
+		str  = newMockStore()
+		mgr  = newMockManager(str)
+		sess = &Session{manager: mgr, id: mockSessionID, ctx: context.Background()}
+	)
+	assert.NoError(t, sess.StoreErr())
+
+	// The error from the store, with its cause, is retained while
+	// the Session returns the package error.
+	errCause := errors.New("connection refused")
+	str.err = NewStoreError(CodeInvalidSession, "invalid session").Wrap(errCause)
+	err := sess.Set("foo", "bar")
+	assert.True(t, err == ErrInvalidSession)
+	assert.Equal(t, str.err, sess.StoreErr())
+	assert.ErrorIs(t, sess.StoreErr(), errCause)
+	assert.Equal(t, errCause, errors.Unwrap(sess.StoreErr()))
+
+	// Errors from the helpers are retained too.
+	str.err = nil
+	_, err = sess.String(nil, NewStoreError(CodeAssertType, "assertion failed").Wrap(errCause))
+	assert.True(t, err == ErrAssertType)
+	assert.ErrorIs(t, sess.StoreErr(), ErrAssertType)
+}
+
 func TestHelpers(t *testing.T) {
 	sess := Session{
 		manager: newMockManager(newMockStore()),
@@ -445,6 +508,68 @@ func TestDelete(t *testing.T) {
 	assert.ErrorIs(t, str.err, err)
 }
 
+func TestBufferedWrites(t *testing.T) {
+	str := newMockStore()
+	mgr := newMockManager(str)
+	mgr.opts.EnableBufferedWrites = true
+	sess, err := mgr.NewSession(nil, nil)
+	assert.NoError(t, err)
+	assert.True(t, sess.Buffered())
+	str.data = map[string]interface{}{
+		"key1": 1,
+		"key2": 2,
+	}
+
+	// Writes are buffered and not sent to the store.
+	assert.NoError(t, sess.Set("key3", 3))
+	assert.NoError(t, sess.SetMulti(map[string]interface{}{"key1": 10, "key4": 4}))
+	assert.NoError(t, sess.Delete("key2", "key4"))
+	assert.Equal(t, map[string]interface{}{"key1": 1, "key2": 2}, str.data)
+
+	// Reads reflect the buffered writes.
+	v, err := sess.Get("key1")
+	assert.NoError(t, err)
+	assert.Equal(t, 10, v)
+	v, err = sess.Get("key2")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	vals, err := sess.GetMulti("key1", "key2", "key3")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"key1": 10, "key2": nil, "key3": 3}, vals)
+
+	all, err := sess.GetAll()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"key1": 10, "key3": 3}, all)
+
+	assert.NoError(t, sess.Cache())
+	assert.Equal(t, all, sess.getCacheAll())
+	sess.ResetCache()
+
+	// The store's data isn't modified by reads.
+	assert.Equal(t, map[string]interface{}{"key1": 1, "key2": 2}, str.data)
+
+	// Commit flushes the writes to the store.
+	assert.NoError(t, sess.Commit())
+	assert.Equal(t, map[string]interface{}{"key1": 10, "key3": 3}, str.data)
+	assert.Nil(t, sess.sets)
+	assert.Nil(t, sess.dels)
+
+	// Nothing to commit.
+	str.err = errors.New("store error")
+	assert.NoError(t, sess.Commit())
+
+	// Writes remain buffered on error.
+	assert.NoError(t, sess.Set("key5", 5))
+	assert.ErrorIs(t, sess.Commit(), str.err)
+	assert.Equal(t, map[string]interface{}{"key5": 5}, sess.sets)
+
+	// Destroy discards the buffered writes.
+	str.err = nil
+	assert.NoError(t, sess.Destroy())
+	assert.Nil(t, sess.sets)
+}
+
 func TestClear(t *testing.T) {
 	// Test errors.
 	str := newMockStore()
@@ -553,4 +678,229 @@ func TestDestroy(t *testing.T) {
 	assert.True(t, isCb)
 	assert.NotNil(t, receCk)
 	assert.Greater(t, time.Now(), receCk.Expires)
+
+	// Sessions that don't exist in the store, eg: expired ones, are destroyed
+	// and their cookie is cleared.
+	receCk = nil
+	str.data = nil
+	assert.NoError(t, sess.Destroy())
+	assert.NotNil(t, receCk)
+	assert.Empty(t, receCk.Value)
+	assert.Greater(t, time.Now(), receCk.Expires)
+}
+
+type mockRenameStore struct {
+	*MockStore
+	oldID string
+	newID string
+}
+
+func (s *mockRenameStore) Rename(id, newID string) error {
+	s.oldID = id
+	s.newID = newID
+	return s.err
+}
+
+func TestRegenerate(t *testing.T) {
+	var (
+		str    = &mockRenameStore{MockStore: newMockStore()}
+		mgr    = New(Options{})
+		receCk *http.Cookie
+	)
+	mgr.UseStore(str)
+	mgr.SetCookieHooks(mockGetCookieCb, func(ck *http.Cookie, w interface{}) error {
+		receCk = ck
+		return nil
+	})
+
+	sess, err := mgr.NewSession(nil, nil)
+	assert.NoError(t, err)
+	oldID := sess.ID()
+
+	// Store's rename is used if available.
+	err = sess.Regenerate()
+	assert.NoError(t, err)
+	assert.NotEqual(t, oldID, sess.ID())
+	assert.True(t, mgr.validateID(sess.ID()))
+	assert.Equal(t, oldID, str.oldID)
+	assert.Equal(t, sess.ID(), str.newID)
+	assert.Equal(t, sess.ID(), receCk.Value)
+
+	// Test store error.
+	str.err = errors.New("store error")
+	newID := sess.ID()
+	err = sess.Regenerate()
+	assert.ErrorIs(t, err, str.err)
+	assert.Equal(t, newID, sess.ID())
+
+	// Test generate error.
+	genErr := errors.New("generate error")
+	mgr.SetSessionIDHooks(func() (string, error) { return "", genErr }, nil)
+	err = sess.Regenerate()
+	assert.ErrorIs(t, err, genErr)
+}
+
+type mockWrapStore struct {
+	Store
+}
+
+func (s mockWrapStore) Unwrap() Store {
+	return s.Store
+}
+
+func TestRegenerateUnwrap(t *testing.T) {
+	var (
+		str = &mockRenameStore{MockStore: newMockStore()}
+		mgr = New(Options{})
+	)
+	mgr.UseStore(mockWrapStore{mockWrapStore{str}})
+	mgr.SetCookieHooks(mockGetCookieCb, mockSetCookieCb)
+
+	sess, err := mgr.NewSession(nil, nil)
+	assert.NoError(t, err)
+	oldID := sess.ID()
+
+	// The wrapped store's rename is used.
+	assert.NoError(t, sess.Regenerate())
+	assert.Equal(t, oldID, str.oldID)
+	assert.Equal(t, sess.ID(), str.newID)
+}
+
+type mockRenameCtxStore struct {
+	*mockRenameStore
+	ctx context.Context
+}
+
+func (s *mockRenameCtxStore) RenameCtx(ctx context.Context, id, newID string) error {
+	s.ctx = ctx
+	return s.Rename(id, newID)
+}
+
+func TestRegenerateContext(t *testing.T) {
+	var (
+		str = &mockRenameCtxStore{mockRenameStore: &mockRenameStore{MockStore: newMockStore()}}
+		mgr = New(Options{})
+		ctx = context.WithValue(context.Background(), ctxNameType("test"), "value")
+	)
+	mgr.UseStore(str)
+	mgr.SetCookieHooks(mockGetCookieCb, mockSetCookieCb)
+
+	sess, err := mgr.Acquire(ctx, nil, nil)
+	assert.NoError(t, err)
+
+	// The session's context is passed to the store's RenameCtx().
+	assert.NoError(t, sess.Regenerate())
+	assert.Equal(t, ctx, str.ctx)
+	assert.Equal(t, sess.ID(), str.newID)
+}
+
+func TestRegenerateCopy(t *testing.T) {
+	var (
+		str    = newMockStore()
+		mgr    = newMockManager(str)
+		receCk *http.Cookie
+	)
+	mgr.SetCookieHooks(mockGetCookieCb, func(ck *http.Cookie, w interface{}) error {
+		receCk = ck
+		return nil
+	})
+
+	sess, err := mgr.NewSession(nil, nil)
+	assert.NoError(t, err)
+	oldID := sess.ID()
+	str.data = map[string]interface{}{"key1": 1}
+
+	// The store can't rename, so the data is copied and the old session is destroyed.
+	err = sess.Regenerate()
+	assert.NoError(t, err)
+	assert.NotEqual(t, oldID, sess.ID())
+	assert.Equal(t, sess.ID(), receCk.Value)
+	assert.Nil(t, str.data)
+}
+
+type mockFlushStore struct {
+	*MockStore
+	flushed int
+	encoded map[string]interface{}
+}
+
+func (s *mockFlushStore) Flush(id string) (string, error) {
+	return "", errors.New("sessions shouldn't flush the store")
+}
+
+func (s *mockFlushStore) Encode(data map[string]interface{}) (string, error) {
+	s.flushed++
+	s.encoded = data
+	return fmt.Sprintf("flushed%d", s.flushed), s.err
+}
+
+func TestFlush(t *testing.T) {
+	var (
+		str    = &mockFlushStore{MockStore: newMockStore()}
+		mgr    = New(Options{})
+		receCk *http.Cookie
+	)
+	mgr.UseStore(str)
+	mgr.SetCookieHooks(mockGetCookieCb, func(ck *http.Cookie, w interface{}) error {
+		receCk = ck
+		return nil
+	})
+
+	// New sessions are flushed and the encoded value is written to the cookie.
+	sess, err := mgr.NewSession(nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "flushed1", sess.ID())
+	assert.Equal(t, "flushed1", receCk.Value)
+
+	// Every write is flushed.
+	assert.NoError(t, sess.Set("key1", 1))
+	assert.Equal(t, "flushed2", sess.ID())
+	assert.Equal(t, "flushed2", receCk.Value)
+
+	assert.Equal(t, map[string]interface{}{"key1": 1}, str.encoded)
+
+	assert.NoError(t, sess.SetMulti(map[string]interface{}{"key2": 2}))
+	assert.NoError(t, sess.Delete("key1"))
+	assert.Equal(t, map[string]interface{}{"key2": 2}, str.encoded)
+
+	// Reads are served from the session's data and aren't flushed.
+	v, err := sess.Get("key2")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, v)
+	assert.Equal(t, 4, str.flushed)
+
+	assert.NoError(t, sess.Clear())
+	assert.Equal(t, "flushed5", receCk.Value)
+	assert.Empty(t, str.encoded)
+
+	// Writes don't reach the store.
+	assert.Empty(t, str.data)
+
+	// Regenerate writes the flushed value instead of the new ID.
+	assert.NoError(t, sess.Regenerate())
+	assert.Equal(t, "flushed6", sess.ID())
+	assert.Equal(t, "flushed6", receCk.Value)
+
+	// Buffered writes are flushed once on commit.
+	mgr.opts.EnableBufferedWrites = true
+	assert.NoError(t, sess.Set("key1", 1))
+	assert.NoError(t, sess.Delete("key2"))
+	assert.Equal(t, 6, str.flushed)
+	assert.NoError(t, sess.Commit())
+	assert.Equal(t, "flushed7", receCk.Value)
+	assert.Equal(t, map[string]interface{}{"key1": 1}, str.encoded)
+
+	// Flush errors are returned.
+	mgr.opts.EnableBufferedWrites = false
+	str.err = errors.New("flush error")
+	assert.ErrorIs(t, sess.Set("key1", 1), str.err)
+
+	// Acquiring doesn't rewrite the cookie if the last seen time is recent.
+	str.err = nil
+	mgr.opts.IdleTimeout = time.Minute
+	str.data = map[string]interface{}{keyCreatedAt: time.Now().Unix(), keyLastSeen: time.Now().Add(-time.Second * 3).Unix()}
+	flushed := str.flushed
+	_, err = mgr.Acquire(context.Background(), nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, flushed, str.flushed)
 }
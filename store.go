@@ -1,5 +1,16 @@
 package simplesessions
 
+import "context"
+
+// Error codes of store errors. Stores return errors with a Code() method,
+// ideally *StoreError, which are mapped by the Session to ErrInvalidSession,
+// ErrNil and ErrAssertType respectively.
+const (
+	CodeInvalidSession = 1
+	CodeNil            = 2
+	CodeAssertType     = 3
+)
+
 // Store represents store interface. This interface can be
 // implemented to create various backend stores for session.
 type Store interface {
@@ -46,3 +57,231 @@ type Store interface {
 	Bytes(interface{}, error) ([]byte, error)
 	Bool(interface{}, error) (bool, error)
 }
+
+// StoreError is an error with a code that stores can return so that the Session
+// can map it to the corresponding error in this package. It can optionally wrap
+// an underlying cause, such as a Redis or SQL error, for callers to inspect with
+// errors.Unwrap() or errors.As().
+//
+//	var ErrInvalidSession = simplesessions.NewStoreError(simplesessions.CodeInvalidSession, "invalid session")
+//	...
+//	return ErrInvalidSession.Wrap(sql.ErrNoRows)
+type StoreError struct {
+	code int
+	msg  string
+	err  error
+}
+
+// NewStoreError returns a new store error with the given code and message.
+func NewStoreError(code int, msg string) *StoreError {
+	return &StoreError{code: code, msg: msg}
+}
+
+// Error returns the message of the error along with the cause, if any.
+func (e *StoreError) Error() string {
+	if e.err != nil {
+		return e.msg + ": " + e.err.Error()
+	}
+	return e.msg
+}
+
+// Code returns the code of the error.
+func (e *StoreError) Code() int {
+	return e.code
+}
+
+// Unwrap returns the underlying cause of the error, if any.
+func (e *StoreError) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether the target is a StoreError with the same code or
+// the error in this package that the code maps to. For instance, an error
+// with CodeInvalidSession matches ErrInvalidSession.
+func (e *StoreError) Is(target error) bool {
+	if t, ok := target.(*StoreError); ok {
+		return t.code == e.code
+	}
+
+	switch target {
+	case ErrInvalidSession:
+		return e.code == CodeInvalidSession
+	case ErrNil:
+		return e.code == CodeNil
+	case ErrAssertType:
+		return e.code == CodeAssertType
+	}
+
+	return false
+}
+
+// Wrap returns a copy of the error that wraps the given cause.
+func (e *StoreError) Wrap(err error) *StoreError {
+	return &StoreError{code: e.code, msg: e.msg, err: err}
+}
+
+// IDValidator is an optional interface that can be implemented by stores
+// whose session IDs don't follow the Manager's ID format. For instance,
+// the securecookie store uses the encoded cookie value itself as the ID.
+// If the store implements it, IsValid() is used to validate session IDs
+// in `Acquire()` instead of the Manager's validator.
+type IDValidator interface {
+	// IsValid checks if the given session ID is valid.
+	IsValid(id string) bool
+}
+
+// Renamer is an optional interface that can be implemented by stores
+// that can move a session's data to a new ID, ideally atomically.
+// It's used by `Session.Regenerate()`. If the store doesn't implement it,
+// the data is copied to a new session and the old one is destroyed.
+type Renamer interface {
+	// Rename moves the session data from the given ID to newID
+	// and deletes the old session.
+	Rename(id, newID string) error
+}
+
+// UserIndexStore is an optional interface that can be implemented by stores
+// that can index sessions by their owner, usually a user ID, to list and revoke
+// all sessions of a user, eg: "log out of all devices". It's used by
+// `Session.SetOwner()`, `Manager.ListUserSessions()` and `Manager.RevokeUserSessions()`.
+// Stores should remove destroyed and expired sessions from the index and retain
+// the owner of sessions that are renamed or cleared.
+type UserIndexStore interface {
+	// SetOwner sets the owner of the session. An empty userID removes the owner.
+	SetOwner(id, userID string) error
+
+	// UserSessions returns the IDs of the live sessions owned by the given user
+	// in the order they were assigned to the user, oldest first. Setting the
+	// same owner again shouldn't change the order.
+	UserSessions(userID string) ([]string, error)
+}
+
+// RenamerContext is the context aware version of Renamer. If the store implements
+// it along with Renamer, `Session.Regenerate()` passes the session's context to it.
+type RenamerContext interface {
+	RenameCtx(ctx context.Context, id, newID string) error
+}
+
+// UserIndexStoreContext is the context aware version of UserIndexStore. If the store
+// implements it along with UserIndexStore, `Session.SetOwner()` passes the session's
+// context to it. The Manager methods, which aren't tied to a request, pass
+// context.Background().
+type UserIndexStoreContext interface {
+	SetOwnerCtx(ctx context.Context, id, userID string) error
+	UserSessionsCtx(ctx context.Context, userID string) ([]string, error)
+}
+
+// Flusher is an optional interface that can be implemented by client-side stores
+// that hold the session data in the cookie itself, such as the securecookie store.
+// Writes to such stores are buffered until flushed. If the store implements it,
+// Session doesn't write to the store but applies the writes to its own copy of the
+// session data so that concurrent requests with the same cookie don't share them.
+// The data is encoded after every write and the encoded value, which becomes the
+// session ID, is written to the cookie.
+type Flusher interface {
+	// Flush returns the encoded session for the given ID with the buffered writes applied.
+	Flush(id string) (string, error)
+
+	// Encode returns the encoded value of the given session data.
+	Encode(data map[string]interface{}) (string, error)
+}
+
+// Unwrapper is an optional interface that can be implemented by stores that wrap
+// another store, eg: to instrument it. The optional interfaces in this package that
+// the wrapper doesn't implement itself, eg: Renamer, are looked up on the wrapped store,
+// except StoreContext, which the wrapper should implement to pass on the context.
+type Unwrapper interface {
+	// Unwrap returns the wrapped store.
+	Unwrap() Store
+}
+
+// storeAs returns the store as T if it, or a store wrapped by it, implements T.
+func storeAs[T any](s Store) (T, bool) {
+	for s != nil {
+		if t, ok := s.(T); ok {
+			return t, true
+		}
+
+		u, ok := s.(Unwrapper)
+		if !ok {
+			break
+		}
+		s = u.Unwrap()
+	}
+
+	var t T
+	return t, false
+}
+
+// StoreContext is an optional interface that can be implemented by stores
+// to receive the context passed to `Manager.Acquire()`, usually the HTTP request
+// context, so that deadlines and cancellations propagate to the backend.
+// If the store implements it, Session uses these methods instead of the ones
+// in Store. The methods behave exactly like their Store counterparts.
+type StoreContext interface {
+	CreateCtx(ctx context.Context, id string) error
+	GetCtx(ctx context.Context, id, key string) (interface{}, error)
+	GetMultiCtx(ctx context.Context, id string, keys ...string) (map[string]interface{}, error)
+	GetAllCtx(ctx context.Context, id string) (map[string]interface{}, error)
+	SetCtx(ctx context.Context, id, key string, value interface{}) error
+	SetMultiCtx(ctx context.Context, id string, data map[string]interface{}) error
+	DeleteCtx(ctx context.Context, id string, key ...string) error
+	ClearCtx(ctx context.Context, id string) error
+	DestroyCtx(ctx context.Context, id string) error
+}
+
+// ctxStore wraps a Store that doesn't implement StoreContext
+// and ignores the context.
+type ctxStore struct {
+	Store
+}
+
+func (s ctxStore) CreateCtx(_ context.Context, id string) error {
+	return s.Create(id)
+}
+
+func (s ctxStore) GetCtx(_ context.Context, id, key string) (interface{}, error) {
+	return s.Get(id, key)
+}
+
+func (s ctxStore) GetMultiCtx(_ context.Context, id string, keys ...string) (map[string]interface{}, error) {
+	return s.GetMulti(id, keys...)
+}
+
+func (s ctxStore) GetAllCtx(_ context.Context, id string) (map[string]interface{}, error) {
+	return s.GetAll(id)
+}
+
+func (s ctxStore) SetCtx(_ context.Context, id, key string, value interface{}) error {
+	return s.Set(id, key, value)
+}
+
+func (s ctxStore) SetMultiCtx(_ context.Context, id string, data map[string]interface{}) error {
+	return s.SetMulti(id, data)
+}
+
+func (s ctxStore) DeleteCtx(_ context.Context, id string, key ...string) error {
+	return s.Delete(id, key...)
+}
+
+func (s ctxStore) ClearCtx(_ context.Context, id string) error {
+	return s.Clear(id)
+}
+
+func (s ctxStore) DestroyCtx(_ context.Context, id string) error {
+	return s.Destroy(id)
+}
+
+// ctxUserIndex wraps a UserIndexStore that doesn't implement
+// UserIndexStoreContext and ignores the context.
+type ctxUserIndex struct {
+	UserIndexStore
+}
+
+func (u ctxUserIndex) SetOwnerCtx(_ context.Context, id, userID string) error {
+	return u.SetOwner(id, userID)
+}
+
+func (u ctxUserIndex) UserSessionsCtx(_ context.Context, userID string) ([]string, error) {
+	return u.UserSessions(userID)
+}
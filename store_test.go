@@ -99,29 +99,57 @@ func (s *MockStore) Destroy(id string) error {
 }
 
 func (s *MockStore) Int(inp interface{}, err error) (int, error) {
-	return inp.(int), err
+	v, ok := inp.(int)
+	if !ok && err == nil {
+		err = ErrAssertType
+	}
+	return v, err
 }
 
 func (s *MockStore) Int64(inp interface{}, err error) (int64, error) {
-	return inp.(int64), err
+	v, ok := inp.(int64)
+	if !ok && err == nil {
+		err = ErrAssertType
+	}
+	return v, err
 }
 
 func (s *MockStore) UInt64(inp interface{}, err error) (uint64, error) {
-	return inp.(uint64), err
+	v, ok := inp.(uint64)
+	if !ok && err == nil {
+		err = ErrAssertType
+	}
+	return v, err
 }
 
 func (s *MockStore) Float64(inp interface{}, err error) (float64, error) {
-	return inp.(float64), err
+	v, ok := inp.(float64)
+	if !ok && err == nil {
+		err = ErrAssertType
+	}
+	return v, err
 }
 
 func (s *MockStore) String(inp interface{}, err error) (string, error) {
-	return inp.(string), err
+	v, ok := inp.(string)
+	if !ok && err == nil {
+		err = ErrAssertType
+	}
+	return v, err
 }
 
 func (s *MockStore) Bytes(inp interface{}, err error) ([]byte, error) {
-	return inp.([]byte), err
+	v, ok := inp.([]byte)
+	if !ok && err == nil {
+		err = ErrAssertType
+	}
+	return v, err
 }
 
 func (s *MockStore) Bool(inp interface{}, err error) (bool, error) {
-	return inp.(bool), err
+	v, ok := inp.(bool)
+	if !ok && err == nil {
+		err = ErrAssertType
+	}
+	return v, err
 }
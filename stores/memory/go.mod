@@ -1,8 +1,11 @@
 module github.com/zerodha/simplesessions/stores/memory/v3
 
-go 1.18
+go 1.21
 
-require github.com/stretchr/testify v1.9.0
+require (
+	github.com/stretchr/testify v1.9.0
+	github.com/zerodha/simplesessions/v3 v3.1.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
@@ -0,0 +1,209 @@
+package memory
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/zerodha/simplesessions/v3"
+)
+
+// snapshotVersion is the version of the snapshot format.
+const snapshotVersion = 2
+
+// snapshot is the gob encoded representation of the store on disk.
+type snapshot struct {
+	Version  int
+	Sessions map[string]snapshotSession
+}
+
+// snapshotSession is a session in the snapshot. Data is the gob encoded session
+// data, encoded separately so that a value that can't be encoded only skips its session.
+type snapshotSession struct {
+	Data    []byte
+	Expiry  time.Time
+	Owner   string
+	OwnedAt time.Time
+}
+
+// NewFromSnapshot creates a new in-memory store instance with the sessions
+// restored from a snapshot file written by Snapshot(). Expired sessions are skipped.
+// If the file doesn't exist, an empty store is returned.
+func NewFromSnapshot(path string) (*Store, error) {
+	s := New()
+
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var snap snapshot
+	if err := gob.NewDecoder(f).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("error decoding snapshot: %v", err)
+	}
+	if snap.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version: %d", snap.Version)
+	}
+
+	now := time.Now()
+	for id, ss := range snap.Sessions {
+		sess := &session{
+			expiry:  ss.Expiry,
+			owner:   ss.Owner,
+			ownedAt: ss.OwnedAt,
+		}
+		if sess.expired(now) {
+			continue
+		}
+		if err := gob.NewDecoder(bytes.NewReader(ss.Data)).Decode(&sess.data); err != nil {
+			return nil, fmt.Errorf("error decoding snapshot session: %v", err)
+		}
+		if sess.data == nil {
+			sess.data = make(map[string]interface{})
+		}
+
+		s.shard(id).sessions[id] = sess
+		s.index(sess.owner, id)
+		s.count++
+	}
+
+	return s, nil
+}
+
+// SnapshotError is returned by Snapshot() if some sessions couldn't be encoded.
+// The snapshot is written without them.
+type SnapshotError struct {
+	// Skipped has the encoding errors of the skipped sessions by session ID.
+	Skipped map[string]error
+}
+
+func (e *SnapshotError) Error() string {
+	for _, err := range e.Skipped {
+		return fmt.Sprintf("%d sessions couldn't be encoded and were skipped, eg: %v", len(e.Skipped), err)
+	}
+	return "no sessions were skipped"
+}
+
+// Snapshot writes all sessions that haven't expired to the given file. The snapshot
+// is written to a temporary file which is then renamed, so an existing snapshot is
+// never left partially written. Values are encoded with encoding/gob, so types other
+// than the primitive types have to be registered with gob.Register(). Sessions with
+// values that can't be encoded are skipped and returned in a *SnapshotError after
+// the snapshot is written.
+func (s *Store) Snapshot(path string) error {
+	snap := snapshot{
+		Version:  snapshotVersion,
+		Sessions: make(map[string]snapshotSession),
+	}
+
+	// Copy the sessions so that the shards aren't locked while encoding.
+	var (
+		now   = time.Now()
+		datas = make(map[string]map[string]interface{})
+	)
+	for _, sh := range s.shards {
+		sh.RLock()
+		for id, sess := range sh.sessions {
+			if sess.expired(now) {
+				continue
+			}
+
+			data := make(map[string]interface{}, len(sess.data))
+			for k, v := range sess.data {
+				data[k] = v
+			}
+			datas[id] = data
+			snap.Sessions[id] = snapshotSession{Expiry: sess.expiry, Owner: sess.owner, OwnedAt: sess.ownedAt}
+		}
+		sh.RUnlock()
+	}
+
+	// Encode the sessions one by one and skip the ones that can't be encoded.
+	var skipped map[string]error
+	for id, data := range datas {
+		var b bytes.Buffer
+		if err := gob.NewEncoder(&b).Encode(data); err != nil {
+			if skipped == nil {
+				skipped = make(map[string]error)
+			}
+			skipped[id] = err
+			delete(snap.Sessions, id)
+
+			if l := s.log.Load(); l != nil {
+				l.Error("error encoding session for snapshot", slog.String("session_id", simplesessions.RedactID(id)), slog.Any("error", err))
+			}
+			continue
+		}
+
+		ss := snap.Sessions[id]
+		ss.Data = b.Bytes()
+		snap.Sessions[id] = ss
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	if err := gob.NewEncoder(f).Encode(snap); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("error encoding snapshot: %v", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if skipped != nil {
+		return &SnapshotError{Skipped: skipped}
+	}
+	return nil
+}
+
+// StartSnapshots starts a background goroutine that writes a snapshot to the given
+// file at the given interval. A final snapshot is written on Close(). Errors from the
+// periodic snapshots are passed to onErr, if it's set.
+func (s *Store) StartSnapshots(path string, interval time.Duration, onErr func(error)) {
+	s.stopMu.Lock()
+	defer s.stopMu.Unlock()
+
+	if s.snapPath != "" {
+		return
+	}
+	s.snapPath = path
+
+	s.every(interval, func() {
+		err := s.Snapshot(path)
+		if err == nil {
+			return
+		}
+
+		if l := s.log.Load(); l != nil {
+			l.Error("error writing snapshot", slog.String("path", path), slog.Any("error", err))
+		}
+		if onErr != nil {
+			onErr(err)
+		}
+	})
+}
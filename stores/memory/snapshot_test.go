@@ -0,0 +1,146 @@
+package memory
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	var (
+		path = filepath.Join(t.TempDir(), "sessions.snap")
+		str  = New()
+	)
+
+	assert.NoError(t, str.Create("id1"))
+	assert.NoError(t, str.SetMulti("id1", map[string]interface{}{
+		"str":   "bar",
+		"int":   10,
+		"bool":  true,
+		"bytes": []byte("abc"),
+	}))
+
+	str.SetTTL(time.Hour, false)
+	assert.NoError(t, str.Create("id2"))
+	assert.NoError(t, str.Set("id2", "foo", 1.5))
+	assert.NoError(t, str.SetOwner("id2", "user"))
+
+	str.SetTTL(time.Millisecond*10, false)
+	assert.NoError(t, str.Create("expired"))
+	time.Sleep(time.Millisecond * 20)
+
+	require.NoError(t, str.Snapshot(path))
+
+	// No temporary files are left behind.
+	files, err := os.ReadDir(filepath.Dir(path))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	str, err = NewFromSnapshot(path)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, str.count)
+	assert.Nil(t, sessionOf(str, "expired"))
+
+	all, err := str.GetAll("id1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"str":   "bar",
+		"int":   10,
+		"bool":  true,
+		"bytes": []byte("abc"),
+	}, all)
+	assert.True(t, sessionOf(str, "id1").expiry.IsZero())
+
+	v, err := str.Get("id2", "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, v)
+	assert.False(t, sessionOf(str, "id2").expiry.IsZero())
+
+	// Owners are restored.
+	ids, err := str.UserSessions("user")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"id2"}, ids)
+
+	// Expired sessions are skipped on restore.
+	sessionOf(str, "id2").expiry = time.Now().Add(time.Millisecond * 10)
+	require.NoError(t, str.Snapshot(path))
+	time.Sleep(time.Millisecond * 20)
+
+	str, err = NewFromSnapshot(path)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, str.count)
+	assert.Nil(t, sessionOf(str, "id2"))
+}
+
+func TestSnapshotErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	// A missing snapshot returns an empty store.
+	str, err := NewFromSnapshot(filepath.Join(dir, "missing"))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, str.count)
+
+	// Corrupt snapshots are rejected.
+	path := filepath.Join(dir, "corrupt")
+	require.NoError(t, os.WriteFile(path, []byte("corrupt"), 0600))
+	_, err = NewFromSnapshot(path)
+	assert.Error(t, err)
+
+	// Sessions with unregistered types can't be encoded and are skipped.
+	type custom struct{ Foo string }
+	str = New()
+	assert.NoError(t, str.Create("id"))
+	assert.NoError(t, str.Set("id", "foo", "bar"))
+	assert.NoError(t, str.Create("custom"))
+	assert.NoError(t, str.Set("custom", "foo", custom{Foo: "bar"}))
+	assert.NoError(t, str.Create("slice"))
+	assert.NoError(t, str.Set("slice", "foo", []interface{}{"bar"}))
+
+	err = str.Snapshot(path)
+	var snapErr *SnapshotError
+	require.ErrorAs(t, err, &snapErr)
+	assert.Len(t, snapErr.Skipped, 2)
+	assert.Contains(t, snapErr.Skipped, "custom")
+	assert.Contains(t, snapErr.Skipped, "slice")
+
+	// The other sessions are written, including on Close.
+	str.StartSnapshots(path, time.Hour, nil)
+	assert.ErrorAs(t, str.Close(), &snapErr)
+
+	str, err = NewFromSnapshot(path)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, str.count)
+	v, err := str.Get("id", "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", v)
+}
+
+func TestStartSnapshots(t *testing.T) {
+	var (
+		path = filepath.Join(t.TempDir(), "sessions.snap")
+		str  = New()
+	)
+	assert.NoError(t, str.Create("id"))
+
+	str.StartSnapshots(path, time.Millisecond*10, func(err error) {
+		t.Error(err)
+	})
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}, time.Second, time.Millisecond*10)
+
+	// A final snapshot is written on Close.
+	assert.NoError(t, str.Set("id", "foo", "bar"))
+	assert.NoError(t, str.Close())
+
+	str, err := NewFromSnapshot(path)
+	require.NoError(t, err)
+	v, err := str.Get("id", "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", v)
+}
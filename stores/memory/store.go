@@ -1,74 +1,302 @@
 package memory
 
 import (
+	"container/list"
+	"context"
+	"log/slog"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zerodha/simplesessions/v3"
 )
 
 var (
-	// Error codes for store errors. This should match the codes
-	// defined in the /simplesessions package exactly.
-	ErrInvalidSession = &Err{code: 1, msg: "invalid session"}
-	ErrNil            = &Err{code: 2, msg: "nil returned"}
-	ErrAssertType     = &Err{code: 3, msg: "assertion failed"}
+	// Errors returned by the store. Their codes map them to the errors in the
+	// simplesessions package. Errors with an underlying cause wrap it.
+	ErrInvalidSession = simplesessions.NewStoreError(simplesessions.CodeInvalidSession, "invalid session")
+	ErrNil            = simplesessions.NewStoreError(simplesessions.CodeNil, "nil returned")
+	ErrAssertType     = simplesessions.NewStoreError(simplesessions.CodeAssertType, "assertion failed")
 )
 
-type Err struct {
-	code int
-	msg  string
-}
+// Number of shards the sessions are split into. Should be a power of 2.
+const numShards = 32
 
-func (e *Err) Error() string {
-	return e.msg
+// Err is the type of the errors returned by the store.
+type Err = simplesessions.StoreError
+
+// Store represents in-memory session store
+type Store struct {
+	// Sessions are split into shards by the hash of their IDs,
+	// each guarded by its own lock, to reduce lock contention.
+	shards [numShards]*shard
+
+	// Number of sessions across all shards.
+	count int64
+
+	// Maximum lifetime of sessions in nanoseconds. Sessions live forever if it's 0.
+	ttl int64
+	// extend TTL on update, 1 if enabled.
+	extendTTL int32
+
+	// Maximum number of sessions. The least recently used session
+	// is evicted to create a new one. Unlimited if it's 0.
+	maxSessions int64
+	// Session IDs ordered by recent use, most recent at the front.
+	// Only maintained if maxSessions is set. Guarded by lruMu, which is
+	// always locked after the shard locks so that reads can update it under RLock.
+	lru   *list.List
+	lruMu sync.Mutex
+
+	// IDs of the sessions of each owner. Guarded by usersMu, which
+	// is always locked after the shard locks.
+	users   map[string]map[string]struct{}
+	usersMu sync.Mutex
+
+	// Stops the janitor and the snapshotter.
+	stop    chan struct{}
+	stopMu  sync.Mutex
+	wg      sync.WaitGroup
+	janitor bool
+
+	// Path of the snapshot written periodically and on Close().
+	snapPath string
+
+	// Logger for the janitor, evictions and snapshots. Disabled if nil.
+	log atomic.Pointer[slog.Logger]
 }
 
-func (e *Err) Code() int {
-	return e.code
+// shard holds a subset of the sessions.
+type shard struct {
+	sync.RWMutex
+
+	// map to store sessions and its values
+	sessions map[string]*session
 }
 
-// Store represents in-memory session store
-type Store struct {
-	// map to store all sessions and its values
-	sessions map[string]map[string]interface{}
+// session holds the values of a session and its metadata.
+type session struct {
+	data map[string]interface{}
+
+	// Expiry time. Zero if the session doesn't expire.
+	expiry time.Time
+
+	// Element in the LRU list.
+	elem *list.Element
 
-	mu sync.RWMutex
+	// Owner of the session, usually a user ID, and when it was set.
+	// Empty if it isn't set.
+	owner   string
+	ownedAt time.Time
 }
 
 // New creates a new in-memory store instance
 func New() *Store {
-	return &Store{
-		sessions: make(map[string]map[string]interface{}),
+	s := &Store{
+		lru:   list.New(),
+		users: make(map[string]map[string]struct{}),
+	}
+	for i := range s.shards {
+		s.shards[i] = &shard{
+			sessions: make(map[string]*session),
+		}
+	}
+
+	return s
+}
+
+// SetTTL sets TTL for sessions. Expired sessions are treated as invalid
+// and are removed by Prune() or the janitor, see StartJanitor().
+// If extend is true then the TTL is updated on all Set/SetMulti,
+// otherwise its set only on Create() and Clear().
+func (s *Store) SetTTL(d time.Duration, extend bool) {
+	var ext int32
+	if extend {
+		ext = 1
+	}
+	atomic.StoreInt32(&s.extendTTL, ext)
+	atomic.StoreInt64(&s.ttl, int64(d))
+}
+
+// SetMaxSessions sets the maximum number of sessions held in the store.
+// If the store is full, creating a session evicts the least recently used one.
+// 0 removes the limit.
+func (s *Store) SetMaxSessions(n int) {
+	s.lockAll()
+
+	// Start tracking existing sessions if the limit is being set now
+	// and stop tracking if it's being removed.
+	if (n > 0) != (atomic.LoadInt64(&s.maxSessions) > 0) {
+		s.lruMu.Lock()
+		s.lru.Init()
+		for _, sh := range s.shards {
+			for id, sess := range sh.sessions {
+				sess.elem = nil
+				if n > 0 {
+					sess.elem = s.lru.PushFront(id)
+				}
+			}
+		}
+		s.lruMu.Unlock()
+	}
+	atomic.StoreInt64(&s.maxSessions, int64(n))
+
+	s.unlockAll()
+	s.evict()
+}
+
+// SetLogger sets the logger for structured logs of the sessions pruned by the
+// janitor, evicted sessions and errors from periodic snapshots. Session IDs are
+// redacted with simplesessions.RedactID(). nil disables logging, which is the default.
+func (s *Store) SetLogger(l *slog.Logger) {
+	s.log.Store(l)
+}
+
+// StartJanitor starts a background goroutine that removes expired sessions
+// at the given interval. Use Close() to stop it.
+func (s *Store) StartJanitor(interval time.Duration) {
+	s.stopMu.Lock()
+	defer s.stopMu.Unlock()
+
+	if s.janitor {
+		return
 	}
+	s.janitor = true
+
+	s.every(interval, func() {
+		n := s.Prune()
+		if l := s.log.Load(); l != nil && n > 0 {
+			l.Debug("pruned expired sessions", slog.Int("count", n))
+		}
+	})
+}
+
+// Close stops the janitor and the snapshotter, if they're running, and waits
+// for them to exit. If snapshotting is enabled, a final snapshot is written.
+func (s *Store) Close() error {
+	s.stopMu.Lock()
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+	s.janitor = false
+
+	path := s.snapPath
+	s.snapPath = ""
+	s.stopMu.Unlock()
+
+	s.wg.Wait()
+
+	if path != "" {
+		return s.Snapshot(path)
+	}
+	return nil
+}
+
+// every runs fn in a goroutine at the given interval until Close() is called.
+// s.stopMu should be locked by the caller.
+func (s *Store) every(interval time.Duration, fn func()) {
+	if s.stop == nil {
+		s.stop = make(chan struct{})
+	}
+
+	s.wg.Add(1)
+	go func(stop chan struct{}) {
+		defer s.wg.Done()
+
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+				fn()
+			}
+		}
+	}(s.stop)
+}
+
+// Prune removes the expired sessions and returns the number of sessions removed.
+func (s *Store) Prune() int {
+	var (
+		now = time.Now()
+		n   = 0
+	)
+	for _, sh := range s.shards {
+		sh.Lock()
+		for id, sess := range sh.sessions {
+			if sess.expired(now) {
+				s.remove(sh, id)
+				n++
+			}
+		}
+		sh.Unlock()
+	}
+
+	return n
 }
 
 // Create creates a new session id and returns it. This doesn't create the session in
 // sessions map since memory can be saved by not storing empty sessions and system
 // can not be stressed by just creating new sessions
 func (s *Store) Create(id string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	sh := s.shard(id)
+	sh.Lock()
 
 	// Check if session already exists.
-	_, ok := s.sessions[id]
-	if ok {
+	if _, ok := s.get(sh, id); ok {
+		sh.Unlock()
 		return nil
 	}
+	s.remove(sh, id)
+
+	sess := &session{
+		data: make(map[string]interface{}),
+	}
+	if ttl := atomic.LoadInt64(&s.ttl); ttl > 0 {
+		sess.expiry = time.Now().Add(time.Duration(ttl))
+	}
+	if atomic.LoadInt64(&s.maxSessions) > 0 {
+		s.lruMu.Lock()
+		sess.elem = s.lru.PushFront(id)
+		s.lruMu.Unlock()
+	}
+	sh.sessions[id] = sess
+	atomic.AddInt64(&s.count, 1)
+	sh.Unlock()
+
+	// Make room for the session.
+	s.evict()
 
-	s.sessions[id] = make(map[string]interface{})
 	return nil
 }
 
+// CreateCtx is the context aware version of Create.
+// It returns the context error if the context is done.
+func (s *Store) CreateCtx(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.Create(id)
+}
+
 // Get gets a field in session
 func (s *Store) Get(id, key string) (interface{}, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	sh := s.shard(id)
+	sh.RLock()
+	defer sh.RUnlock()
 
 	// Check if session exists before accessing key from it.
-	sess, ok := s.sessions[id]
+	sess, ok := s.get(sh, id)
 	if !ok {
 		return nil, ErrInvalidSession
 	}
 
-	val, ok := sess[key]
+	val, ok := sess.data[key]
 	if !ok {
 		return nil, nil
 	}
@@ -76,19 +304,30 @@ func (s *Store) Get(id, key string) (interface{}, error) {
 	return val, nil
 }
 
+// GetCtx is the context aware version of Get.
+// It returns the context error if the context is done.
+func (s *Store) GetCtx(ctx context.Context, id, key string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.Get(id, key)
+}
+
 // GetMulti gets a map for values for multiple keys. If key is not present in session then nil is returned.
 func (s *Store) GetMulti(id string, keys ...string) (map[string]interface{}, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	sh := s.shard(id)
+	sh.RLock()
+	defer sh.RUnlock()
 
-	sess, ok := s.sessions[id]
+	sess, ok := s.get(sh, id)
 	if !ok {
 		return nil, ErrInvalidSession
 	}
 
 	out := make(map[string]interface{})
 	for _, k := range keys {
-		v, ok := sess[k]
+		v, ok := sess.data[k]
 		if !ok {
 			out[k] = nil
 		} else {
@@ -99,106 +338,453 @@ func (s *Store) GetMulti(id string, keys ...string) (map[string]interface{}, err
 	return out, nil
 }
 
+// GetMultiCtx is the context aware version of GetMulti.
+// It returns the context error if the context is done.
+func (s *Store) GetMultiCtx(ctx context.Context, id string, keys ...string) (map[string]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.GetMulti(id, keys...)
+}
+
 // GetAll gets all fields in session
 func (s *Store) GetAll(id string) (map[string]interface{}, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	sh := s.shard(id)
+	sh.RLock()
+	defer sh.RUnlock()
 
-	sess, ok := s.sessions[id]
+	sess, ok := s.get(sh, id)
 	if !ok {
 		return nil, ErrInvalidSession
 	}
 
 	// Copy the map.
 	out := make(map[string]interface{})
-	for k, v := range sess {
+	for k, v := range sess.data {
 		out[k] = v
 	}
 
 	return out, nil
 }
 
+// GetAllCtx is the context aware version of GetAll.
+// It returns the context error if the context is done.
+func (s *Store) GetAllCtx(ctx context.Context, id string) (map[string]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.GetAll(id)
+}
+
 // Set sets a value to given session.
 func (s *Store) Set(id, key string, val interface{}) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	sh := s.shard(id)
+	sh.Lock()
+	defer sh.Unlock()
 
-	_, ok := s.sessions[id]
+	sess, ok := s.get(sh, id)
 	if !ok {
 		return ErrInvalidSession
 	}
-	s.sessions[id][key] = val
+	sess.data[key] = val
+	s.extend(sess)
+
 	return nil
 }
 
+// SetCtx is the context aware version of Set.
+// It returns the context error if the context is done.
+func (s *Store) SetCtx(ctx context.Context, id, key string, val interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.Set(id, key, val)
+}
+
 // SetMulti sets multiple key value pair to given session.
 func (s *Store) SetMulti(id string, data map[string]interface{}) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	sh := s.shard(id)
+	sh.Lock()
+	defer sh.Unlock()
 
-	_, ok := s.sessions[id]
+	sess, ok := s.get(sh, id)
 	if !ok {
 		return ErrInvalidSession
 	}
 
 	for k, v := range data {
-		s.sessions[id][k] = v
+		sess.data[k] = v
 	}
+	s.extend(sess)
 
 	return nil
 }
 
+// SetMultiCtx is the context aware version of SetMulti.
+// It returns the context error if the context is done.
+func (s *Store) SetMultiCtx(ctx context.Context, id string, data map[string]interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.SetMulti(id, data)
+}
+
 // Delete deletes a key from session.
 func (s *Store) Delete(id string, keys ...string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	sh := s.shard(id)
+	sh.Lock()
+	defer sh.Unlock()
 
-	_, ok := s.sessions[id]
+	sess, ok := s.get(sh, id)
 	if !ok {
 		return ErrInvalidSession
 	}
 
 	for _, k := range keys {
-		delete(s.sessions[id], k)
+		delete(sess.data, k)
 	}
 
 	return nil
 }
 
+// DeleteCtx is the context aware version of Delete.
+// It returns the context error if the context is done.
+func (s *Store) DeleteCtx(ctx context.Context, id string, keys ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.Delete(id, keys...)
+}
+
 // Clear empties the session.
 func (s *Store) Clear(id string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	sh := s.shard(id)
+	sh.Lock()
+	defer sh.Unlock()
 
-	_, ok := s.sessions[id]
+	sess, ok := s.get(sh, id)
 	if !ok {
 		return ErrInvalidSession
 	}
-	s.sessions[id] = make(map[string]interface{})
+	sess.data = make(map[string]interface{})
+	if ttl := atomic.LoadInt64(&s.ttl); ttl > 0 {
+		sess.expiry = time.Now().Add(time.Duration(ttl))
+	}
 
 	return nil
 }
 
+// ClearCtx is the context aware version of Clear.
+// It returns the context error if the context is done.
+func (s *Store) ClearCtx(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.Clear(id)
+}
+
 // Destroy deletes the entire session.
 func (s *Store) Destroy(id string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	sh := s.shard(id)
+	sh.Lock()
+	defer sh.Unlock()
+
+	if _, ok := s.get(sh, id); !ok {
+		return ErrInvalidSession
+	}
+	s.remove(sh, id)
+
+	return nil
+}
+
+// DestroyCtx is the context aware version of Destroy.
+// It returns the context error if the context is done.
+func (s *Store) DestroyCtx(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-	_, ok := s.sessions[id]
+	return s.Destroy(id)
+}
+
+// Rename moves the session data to a new ID and deletes the old session.
+// The expiry of the session is retained.
+func (s *Store) Rename(id, newID string) error {
+	var (
+		from = s.shard(id)
+		to   = s.shard(newID)
+	)
+
+	// Lock the shards in a fixed order to avoid deadlocks.
+	first, second := from, to
+	if shardIndex(newID) < shardIndex(id) {
+		first, second = to, from
+	}
+	first.Lock()
+	defer first.Unlock()
+	if second != first {
+		second.Lock()
+		defer second.Unlock()
+	}
+
+	sess, ok := s.get(from, id)
 	if !ok {
 		return ErrInvalidSession
 	}
-	delete(s.sessions, id)
+	delete(from.sessions, id)
+	atomic.AddInt64(&s.count, -1)
+	s.remove(to, newID)
+
+	if sess.elem != nil {
+		s.lruMu.Lock()
+		sess.elem.Value = newID
+		s.lru.MoveToFront(sess.elem)
+		s.lruMu.Unlock()
+	}
+	if sess.owner != "" {
+		s.usersMu.Lock()
+		s.unindex(sess.owner, id)
+		s.index(sess.owner, newID)
+		s.usersMu.Unlock()
+	}
+	to.sessions[newID] = sess
+	atomic.AddInt64(&s.count, 1)
 
 	return nil
 }
 
+// SetOwner sets the owner of the session, usually a user ID, to list its sessions
+// with UserSessions(). An empty userID removes the owner. Setting the same owner
+// again doesn't change the order of the session in UserSessions().
+func (s *Store) SetOwner(id, userID string) error {
+	sh := s.shard(id)
+	sh.Lock()
+	defer sh.Unlock()
+
+	sess, ok := s.get(sh, id)
+	if !ok {
+		return ErrInvalidSession
+	}
+	if sess.owner == userID {
+		return nil
+	}
+
+	s.usersMu.Lock()
+	s.unindex(sess.owner, id)
+	s.index(userID, id)
+	s.usersMu.Unlock()
+	sess.owner = userID
+	sess.ownedAt = time.Now()
+
+	return nil
+}
+
+// UserSessions returns the IDs of the sessions owned by the given user that
+// haven't expired, in the order they were assigned to the user, oldest first.
+func (s *Store) UserSessions(userID string) ([]string, error) {
+	s.usersMu.Lock()
+	ids := make([]string, 0, len(s.users[userID]))
+	for id := range s.users[userID] {
+		ids = append(ids, id)
+	}
+	s.usersMu.Unlock()
+
+	// The shards are locked after usersMu is released to keep the lock order.
+	// Sessions may have changed in between, so they're checked again.
+	type owned struct {
+		id string
+		at time.Time
+	}
+	var (
+		now  = time.Now()
+		live = make([]owned, 0, len(ids))
+	)
+	for _, id := range ids {
+		sh := s.shard(id)
+		sh.RLock()
+		if sess, ok := sh.sessions[id]; ok && sess.owner == userID && !sess.expired(now) {
+			live = append(live, owned{id: id, at: sess.ownedAt})
+		}
+		sh.RUnlock()
+	}
+
+	sort.Slice(live, func(i, j int) bool {
+		return live[i].at.Before(live[j].at)
+	})
+
+	out := make([]string, len(live))
+	for i, o := range live {
+		out[i] = o.id
+	}
+
+	return out, nil
+}
+
+// shard returns the shard the session ID belongs to.
+func (s *Store) shard(id string) *shard {
+	return s.shards[shardIndex(id)]
+}
+
+// shardIndex returns the index of the shard for the session ID
+// with the FNV-1a hash of the ID.
+func shardIndex(id string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(id); i++ {
+		h ^= uint32(id[i])
+		h *= 16777619
+	}
+
+	return h & (numShards - 1)
+}
+
+// lockAll locks all shards in order.
+func (s *Store) lockAll() {
+	for _, sh := range s.shards {
+		sh.Lock()
+	}
+}
+
+// unlockAll unlocks all shards.
+func (s *Store) unlockAll() {
+	for _, sh := range s.shards {
+		sh.Unlock()
+	}
+}
+
+// get returns the session if it exists and hasn't expired and marks it
+// as recently used. The shard should be locked, at least for reading, by the caller.
+func (s *Store) get(sh *shard, id string) (*session, bool) {
+	sess, ok := sh.sessions[id]
+	if !ok || sess.expired(time.Now()) {
+		return nil, false
+	}
+
+	if sess.elem != nil {
+		s.lruMu.Lock()
+		s.lru.MoveToFront(sess.elem)
+		s.lruMu.Unlock()
+	}
+
+	return sess, true
+}
+
+// extend extends the expiry of the session if extendTTL is set.
+// The shard should be locked by the caller.
+func (s *Store) extend(sess *session) {
+	if ttl := atomic.LoadInt64(&s.ttl); ttl > 0 && atomic.LoadInt32(&s.extendTTL) == 1 {
+		sess.expiry = time.Now().Add(time.Duration(ttl))
+	}
+}
+
+// remove removes the session, if it exists. The shard should be locked by the caller.
+func (s *Store) remove(sh *shard, id string) {
+	sess, ok := sh.sessions[id]
+	if !ok {
+		return
+	}
+
+	if sess.elem != nil {
+		s.lruMu.Lock()
+		s.lru.Remove(sess.elem)
+		s.lruMu.Unlock()
+	}
+	if sess.owner != "" {
+		s.usersMu.Lock()
+		s.unindex(sess.owner, id)
+		s.usersMu.Unlock()
+	}
+	delete(sh.sessions, id)
+	atomic.AddInt64(&s.count, -1)
+}
+
+// index adds the session ID to the sessions of the owner, if it isn't empty.
+// usersMu should be locked by the caller.
+func (s *Store) index(owner, id string) {
+	if owner == "" {
+		return
+	}
+
+	ids, ok := s.users[owner]
+	if !ok {
+		ids = make(map[string]struct{})
+		s.users[owner] = ids
+	}
+	ids[id] = struct{}{}
+}
+
+// unindex removes the session ID from the sessions of the owner.
+// usersMu should be locked by the caller.
+func (s *Store) unindex(owner, id string) {
+	ids, ok := s.users[owner]
+	if !ok {
+		return
+	}
+
+	delete(ids, id)
+	if len(ids) == 0 {
+		delete(s.users, owner)
+	}
+}
+
+// evict removes the least recently used sessions until the number of sessions
+// is within the limit. No shard should be locked by the caller.
+func (s *Store) evict() {
+	for {
+		max := atomic.LoadInt64(&s.maxSessions)
+		if max <= 0 || atomic.LoadInt64(&s.count) <= max {
+			return
+		}
+
+		s.lruMu.Lock()
+		e := s.lru.Back()
+		var id string
+		if e != nil {
+			id = e.Value.(string)
+		}
+		s.lruMu.Unlock()
+
+		if e == nil {
+			return
+		}
+
+		// The session may have been renamed or removed since the shard wasn't
+		// locked. If so, the next iteration picks up the new LRU session.
+		sh := s.shard(id)
+		sh.Lock()
+		sess, ok := sh.sessions[id]
+		evicted := ok && sess.elem == e
+		if evicted {
+			s.remove(sh, id)
+		}
+		sh.Unlock()
+
+		if l := s.log.Load(); l != nil && evicted {
+			l.Debug("evicted session", slog.String("session_id", simplesessions.RedactID(id)))
+		}
+	}
+}
+
+// expired checks if the session has expired at the given time.
+func (sess *session) expired(now time.Time) bool {
+	return !sess.expiry.IsZero() && now.After(sess.expiry)
+}
+
 // Int is a helper method to type assert as integer
 func (s *Store) Int(r interface{}, err error) (int, error) {
 	if err != nil {
 		return 0, err
 	}
 
+	if r == nil {
+		return 0, ErrNil
+	}
+
 	v, ok := r.(int)
 	if !ok {
 		err = ErrAssertType
@@ -213,6 +799,10 @@ func (s *Store) Int64(r interface{}, err error) (int64, error) {
 		return 0, err
 	}
 
+	if r == nil {
+		return 0, ErrNil
+	}
+
 	v, ok := r.(int64)
 	if !ok {
 		err = ErrAssertType
@@ -227,6 +817,10 @@ func (s *Store) UInt64(r interface{}, err error) (uint64, error) {
 		return 0, err
 	}
 
+	if r == nil {
+		return 0, ErrNil
+	}
+
 	v, ok := r.(uint64)
 	if !ok {
 		err = ErrAssertType
@@ -241,6 +835,10 @@ func (s *Store) Float64(r interface{}, err error) (float64, error) {
 		return 0, err
 	}
 
+	if r == nil {
+		return 0, ErrNil
+	}
+
 	v, ok := r.(float64)
 	if !ok {
 		err = ErrAssertType
@@ -255,6 +853,10 @@ func (s *Store) String(r interface{}, err error) (string, error) {
 		return "", err
 	}
 
+	if r == nil {
+		return "", ErrNil
+	}
+
 	v, ok := r.(string)
 	if !ok {
 		err = ErrAssertType
@@ -269,6 +871,10 @@ func (s *Store) Bytes(r interface{}, err error) ([]byte, error) {
 		return nil, err
 	}
 
+	if r == nil {
+		return nil, ErrNil
+	}
+
 	v, ok := r.([]byte)
 	if !ok {
 		err = ErrAssertType
@@ -283,6 +889,10 @@ func (s *Store) Bool(r interface{}, err error) (bool, error) {
 		return false, err
 	}
 
+	if r == nil {
+		return false, ErrNil
+	}
+
 	v, ok := r.(bool)
 	if !ok {
 		err = ErrAssertType
@@ -1,16 +1,44 @@
 package memory
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/zerodha/simplesessions/v3"
+	"github.com/zerodha/simplesessions/v3/storetest"
 )
 
+// putSession adds a session with the given data to the store.
+func putSession(str *Store, id string, data map[string]interface{}) {
+	str.shard(id).sessions[id] = &session{data: data}
+	str.count++
+}
+
+// sessionOf returns the session from the store, nil if it doesn't exist.
+func sessionOf(str *Store, id string) *session {
+	return str.shard(id).sessions[id]
+}
+
 func TestNew(t *testing.T) {
 	assert := assert.New(t)
 	str := New()
-	assert.NotNil(str.sessions)
+	for _, sh := range str.shards {
+		assert.NotNil(sh.sessions)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	storetest.RunConformance(t, func() simplesessions.Store {
+		return New()
+	})
 }
 
 func TestCreate(t *testing.T) {
@@ -18,17 +46,17 @@ func TestCreate(t *testing.T) {
 		id  = "testid"
 		str = New()
 	)
-	assert.NotContains(t, str.sessions, id)
+	assert.Nil(t, sessionOf(str, id))
 	err := str.Create(id)
 	assert.NoError(t, err)
-	assert.NotNil(t, str.sessions, id)
+	assert.NotNil(t, sessionOf(str, id))
 
 	// Check if existing session is not overwritten on Create.
 	val := map[string]interface{}{"foo": "bar"}
-	str.sessions["existing_id"] = val
+	putSession(str, "existing_id", val)
 	err = str.Create("existing_id")
 	assert.NoError(t, err)
-	assert.Equal(t, val, str.sessions["existing_id"])
+	assert.Equal(t, val, sessionOf(str, "existing_id").data)
 }
 
 func TestGet(t *testing.T) {
@@ -42,8 +70,7 @@ func TestGet(t *testing.T) {
 	_, err := str.Get("invalidkey", "invalidkey")
 	assert.ErrorIs(t, ErrInvalidSession, err)
 
-	str.sessions[id] = make(map[string]interface{})
-	str.sessions[id][field] = value
+	putSession(str, id, map[string]interface{}{field: value})
 
 	val, err := str.Get(id, field)
 	assert.NoError(t, err)
@@ -69,9 +96,7 @@ func TestGetMulti(t *testing.T) {
 	)
 
 	// Set a key
-	str.sessions[id] = make(map[string]interface{})
-	str.sessions[id][field1] = value1
-	str.sessions[id][field2] = value2
+	putSession(str, id, map[string]interface{}{field1: value1, field2: value2})
 
 	vals, err := str.GetMulti(id, field1, field2, field3)
 	assert.NoError(t, err)
@@ -100,9 +125,7 @@ func TestGetAll(t *testing.T) {
 	)
 
 	// Set a key
-	str.sessions[key] = make(map[string]interface{})
-	str.sessions[key][field1] = value1
-	str.sessions[key][field2] = value2
+	putSession(str, key, map[string]interface{}{field1: value1, field2: value2})
 
 	vals, err := str.GetAll(key)
 	assert.NoError(t, err)
@@ -125,16 +148,16 @@ func TestSet(t *testing.T) {
 		field = "somekey"
 		value = 100
 	)
-	assert.NotContains(t, str.sessions, id)
+	assert.Nil(t, sessionOf(str, id))
 
-	str.sessions[id] = map[string]interface{}{
+	putSession(str, id, map[string]interface{}{
 		field: value,
-	}
+	})
 	err = str.Set(id, field, value)
 	assert.NoError(t, err)
-	assert.Contains(t, str.sessions, id)
-	assert.Contains(t, str.sessions[id], field)
-	assert.Equal(t, value, str.sessions[id][field])
+	assert.NotNil(t, sessionOf(str, id))
+	assert.Contains(t, sessionOf(str, id).data, field)
+	assert.Equal(t, value, sessionOf(str, id).data[field])
 }
 
 func TestSetMulti(t *testing.T) {
@@ -150,17 +173,17 @@ func TestSetMulti(t *testing.T) {
 		field2 = "somekey2"
 		value2 = 100
 	)
-	str.sessions[id] = map[string]interface{}{}
+	putSession(str, id, map[string]interface{}{})
 	err = str.SetMulti(id, map[string]interface{}{
 		field1: value1,
 		field2: value2,
 	})
 	assert.NoError(t, err)
-	assert.Contains(t, str.sessions, id)
-	assert.Contains(t, str.sessions[id], field1)
-	assert.Contains(t, str.sessions[id], field2)
-	assert.Equal(t, value1, str.sessions[id][field1])
-	assert.Equal(t, value2, str.sessions[id][field2])
+	assert.NotNil(t, sessionOf(str, id))
+	assert.Contains(t, sessionOf(str, id).data, field1)
+	assert.Contains(t, sessionOf(str, id).data, field2)
+	assert.Equal(t, value1, sessionOf(str, id).data[field1])
+	assert.Equal(t, value2, sessionOf(str, id).data[field2])
 }
 
 func TestDelete(t *testing.T) {
@@ -175,14 +198,12 @@ func TestDelete(t *testing.T) {
 		field1 = "somefield1"
 		field2 = "somefield2"
 	)
-	str.sessions[key] = make(map[string]interface{})
-	str.sessions[key][field1] = 10
-	str.sessions[key][field2] = 10
+	putSession(str, key, map[string]interface{}{field1: 10, field2: 10})
 
 	err = str.Delete(key, field1)
 	assert.NoError(t, err)
-	assert.Contains(t, str.sessions[key], field2)
-	assert.NotContains(t, str.sessions[key], field1)
+	assert.Contains(t, sessionOf(str, key).data, field2)
+	assert.NotContains(t, sessionOf(str, key).data, field1)
 }
 
 func TestClear(t *testing.T) {
@@ -193,12 +214,12 @@ func TestClear(t *testing.T) {
 
 	// this id is unique across all tests
 	id := "test_id"
-	str.sessions[id] = make(map[string]interface{})
+	putSession(str, id, make(map[string]interface{}))
 
 	err = str.Clear(id)
 	assert.NoError(t, err)
-	assert.Contains(t, str.sessions, id)
-	assert.Equal(t, len(str.sessions[id]), 0)
+	assert.NotNil(t, sessionOf(str, id))
+	assert.Equal(t, len(sessionOf(str, id).data), 0)
 }
 
 func TestDestroy(t *testing.T) {
@@ -209,11 +230,253 @@ func TestDestroy(t *testing.T) {
 
 	// this id is unique across all tests
 	id := "test_id"
-	str.sessions[id] = make(map[string]interface{})
+	putSession(str, id, make(map[string]interface{}))
 
 	err = str.Destroy(id)
 	assert.NoError(t, err)
-	assert.NotContains(t, str.sessions, id)
+	assert.Nil(t, sessionOf(str, id))
+}
+
+func TestRename(t *testing.T) {
+	str := New()
+	err := str.Rename("invalidkey", "newkey")
+	assert.ErrorIs(t, ErrInvalidSession, err)
+
+	id := "test_id"
+	putSession(str, id, map[string]interface{}{"foo": "bar"})
+
+	err = str.Rename(id, "new_id")
+	assert.NoError(t, err)
+	assert.Nil(t, sessionOf(str, id))
+	assert.Equal(t, "bar", sessionOf(str, "new_id").data["foo"])
+}
+
+func TestContext(t *testing.T) {
+	var (
+		id  = "test_id"
+		str = New()
+	)
+	assert.NoError(t, str.CreateCtx(context.Background(), id))
+	assert.NoError(t, str.SetCtx(context.Background(), id, "foo", "bar"))
+
+	v, err := str.GetCtx(context.Background(), id, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", v)
+
+	// Cancelled contexts are rejected.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = str.GetCtx(ctx, id, "foo")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, str.SetCtx(ctx, id, "foo", "baz"), context.Canceled)
+	assert.Equal(t, "bar", sessionOf(str, id).data["foo"])
+}
+
+func TestTTL(t *testing.T) {
+	str := New()
+	str.SetTTL(time.Millisecond*50, false)
+
+	assert.NoError(t, str.Create("id"))
+	assert.False(t, sessionOf(str, "id").expiry.IsZero())
+
+	// Updates don't extend the TTL.
+	exp := sessionOf(str, "id").expiry
+	assert.NoError(t, str.Set("id", "foo", "bar"))
+	assert.Equal(t, exp, sessionOf(str, "id").expiry)
+
+	time.Sleep(time.Millisecond * 60)
+	_, err := str.Get("id", "foo")
+	assert.ErrorIs(t, err, ErrInvalidSession)
+	assert.ErrorIs(t, str.Set("id", "foo", "bar"), ErrInvalidSession)
+
+	// Expired sessions can be created again.
+	assert.NoError(t, str.Create("id"))
+	v, err := str.Get("id", "foo")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	// Updates extend the TTL.
+	str.SetTTL(time.Millisecond*50, true)
+	for i := 0; i < 3; i++ {
+		time.Sleep(time.Millisecond * 30)
+		assert.NoError(t, str.Set("id", "foo", "bar"))
+	}
+	v, err = str.Get("id", "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", v)
+}
+
+func TestMaxSessions(t *testing.T) {
+	str := New()
+	assert.NoError(t, str.Create("x"))
+
+	// Existing sessions are tracked when the limit is set.
+	str.SetMaxSessions(3)
+	assert.Equal(t, 1, str.lru.Len())
+
+	for _, id := range []string{"a", "b", "c"} {
+		assert.NoError(t, str.Create(id))
+	}
+	assert.EqualValues(t, 3, str.count)
+	assert.Nil(t, sessionOf(str, "x"))
+
+	// Lowering the limit evicts the oldest sessions.
+	str.SetMaxSessions(2)
+	assert.EqualValues(t, 2, str.count)
+	assert.Equal(t, 2, str.lru.Len())
+	assert.Nil(t, sessionOf(str, "a"))
+
+	// Access "b" so that "c" is the least recently used.
+	_, err := str.Get("b", "foo")
+	assert.NoError(t, err)
+
+	assert.NoError(t, str.Create("d"))
+	assert.EqualValues(t, 2, str.count)
+	assert.NotNil(t, sessionOf(str, "b"))
+	assert.NotNil(t, sessionOf(str, "d"))
+
+	// Renamed sessions are tracked by their new ID.
+	assert.NoError(t, str.Rename("b", "e"))
+	assert.NoError(t, str.Create("f"))
+	assert.EqualValues(t, 2, str.count)
+	assert.NotNil(t, sessionOf(str, "e"))
+	assert.NotNil(t, sessionOf(str, "f"))
+
+	assert.NoError(t, str.Destroy("e"))
+	assert.Equal(t, 1, str.lru.Len())
+
+	// Removing the limit stops tracking.
+	str.SetMaxSessions(0)
+	assert.Equal(t, 0, str.lru.Len())
+	assert.Nil(t, sessionOf(str, "f").elem)
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	str := New()
+	str.SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	str.SetMaxSessions(1)
+
+	assert.NoError(t, str.Create("abcdefgh"))
+	assert.NoError(t, str.Create("ijklmnop"))
+	assert.Contains(t, buf.String(), "msg=\"evicted session\" session_id=abcd***")
+	assert.NotContains(t, buf.String(), "abcdefgh")
+
+	// Pruned sessions are logged by the janitor.
+	buf.Reset()
+	str.SetTTL(time.Millisecond*10, false)
+	assert.NoError(t, str.Create("qrstuvwx"))
+	str.StartJanitor(time.Millisecond * 10)
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt64(&str.count) == 0
+	}, time.Second, time.Millisecond*10)
+	assert.NoError(t, str.Close())
+	assert.Contains(t, buf.String(), "msg=\"pruned expired sessions\" count=1")
+}
+
+func TestPrune(t *testing.T) {
+	str := New()
+	assert.NoError(t, str.Create("forever"))
+
+	str.SetTTL(time.Millisecond*10, false)
+	assert.NoError(t, str.Create("a"))
+	assert.NoError(t, str.Create("b"))
+	assert.Equal(t, 0, str.Prune())
+
+	time.Sleep(time.Millisecond * 20)
+	assert.Equal(t, 2, str.Prune())
+	assert.EqualValues(t, 1, str.count)
+	assert.NotNil(t, sessionOf(str, "forever"))
+}
+
+func TestJanitor(t *testing.T) {
+	str := New()
+	str.SetTTL(time.Millisecond*10, false)
+	assert.NoError(t, str.Create("id"))
+
+	str.StartJanitor(time.Millisecond * 10)
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt64(&str.count) == 0
+	}, time.Second, time.Millisecond*10)
+
+	assert.NoError(t, str.Close())
+	assert.Nil(t, str.stop)
+
+	// Close is idempotent.
+	assert.NoError(t, str.Close())
+}
+
+func TestUserSessions(t *testing.T) {
+	str := New()
+	for _, id := range []string{"a", "b", "c"} {
+		assert.NoError(t, str.Create(id))
+		assert.NoError(t, str.SetOwner(id, "user"))
+	}
+
+	// Expired sessions aren't listed and are unindexed when pruned.
+	sessionOf(str, "a").expiry = time.Now().Add(-time.Second)
+	ids, err := str.UserSessions("user")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"b", "c"}, ids)
+
+	assert.Equal(t, 1, str.Prune())
+	assert.Len(t, str.users["user"], 2)
+
+	// Evicted sessions are unindexed.
+	str.SetMaxSessions(1)
+	ids, err = str.UserSessions("user")
+	assert.NoError(t, err)
+	assert.Len(t, ids, 1)
+	assert.Len(t, str.users["user"], 1)
+
+	// Owners without sessions are removed.
+	assert.NoError(t, str.Destroy(ids[0]))
+	assert.Empty(t, str.users)
+}
+
+func TestConcurrency(t *testing.T) {
+	str := New()
+	str.SetTTL(time.Minute, true)
+	str.SetMaxSessions(50)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				id := strconv.Itoa(n*1000 + j%100)
+				str.Create(id)
+				str.Set(id, "foo", j)
+				str.Get(id, "foo")
+				if j%10 == 0 {
+					str.Rename(id, id+"_new")
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// The session count and the LRU list should agree with the shards.
+	var n int
+	for _, sh := range str.shards {
+		n += len(sh.sessions)
+	}
+	assert.EqualValues(t, n, str.count)
+	assert.Equal(t, n, str.lru.Len())
+	assert.LessOrEqual(t, n, 50)
+}
+
+func TestShardIndex(t *testing.T) {
+	seen := make(map[uint32]bool)
+	for i := 0; i < 1000; i++ {
+		idx := shardIndex(strconv.Itoa(i))
+		assert.Less(t, idx, uint32(numShards))
+		seen[idx] = true
+	}
+
+	// IDs should be spread across all shards.
+	assert.Len(t, seen, numShards)
 }
 
 func TestInt(t *testing.T) {
@@ -336,10 +599,54 @@ func TestBool(t *testing.T) {
 }
 
 func TestError(t *testing.T) {
-	err := Err{
-		code: 1,
-		msg:  "test",
+	assert.Equal(t, simplesessions.CodeInvalidSession, ErrInvalidSession.Code())
+	assert.Equal(t, simplesessions.CodeNil, ErrNil.Code())
+	assert.Equal(t, simplesessions.CodeAssertType, ErrAssertType.Code())
+	assert.ErrorIs(t, ErrInvalidSession, simplesessions.ErrInvalidSession)
+
+	// Causes are wrapped.
+	err := ErrInvalidSession.Wrap(errors.New("test"))
+	assert.Equal(t, "invalid session: test", err.Error())
+	assert.ErrorIs(t, err, ErrInvalidSession)
+	assert.EqualError(t, errors.Unwrap(err), "test")
+}
+
+func benchmarkStore(b *testing.B, str *Store) {
+	// Sessions to read and write to.
+	ids := make([]string, 1000)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i)
+		str.Create(ids[i])
 	}
-	assert.Equal(t, 1, err.Code())
-	assert.Equal(t, "test", err.Error())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			id := ids[i%len(ids)]
+			if i%4 == 0 {
+				str.Set(id, "foo", i)
+			} else {
+				str.Get(id, "foo")
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkSetGet(b *testing.B) {
+	benchmarkStore(b, New())
+}
+
+func BenchmarkSetGetTTL(b *testing.B) {
+	str := New()
+	str.SetTTL(time.Hour, true)
+	benchmarkStore(b, str)
+}
+
+func BenchmarkSetGetMaxSessions(b *testing.B) {
+	str := New()
+	str.SetMaxSessions(10000)
+	benchmarkStore(b, str)
 }
@@ -0,0 +1,148 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// migration is a versioned schema change. Statements are templates that take
+// the table name and its sanitized name, for naming indexes, in that order.
+type migration struct {
+	version int
+	stmts   []string
+}
+
+// migrations to be applied in order. The statements should be idempotent so
+// that they apply cleanly on tables created by hand before migrations existed.
+// New migrations should only ever be appended.
+var migrations = []migration{
+	{
+		version: 1,
+		stmts: []string{
+			`CREATE TABLE IF NOT EXISTS %[1]s (
+				id TEXT NOT NULL PRIMARY KEY,
+				data JSONB DEFAULT '{}'::JSONB NOT NULL,
+				created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW() NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_%[2]s ON %[1]s (id, created_at)`,
+		},
+	},
+	{
+		version: 2,
+		stmts: []string{
+			`ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW() NOT NULL`,
+			`CREATE INDEX IF NOT EXISTS idx_%[2]s_updated_at ON %[1]s (updated_at)`,
+		},
+	},
+	{
+		version: 3,
+		stmts: []string{
+			`ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS owner TEXT`,
+			`CREATE INDEX IF NOT EXISTS idx_%[2]s_owner ON %[1]s (owner)`,
+		},
+	},
+	{
+		version: 4,
+		stmts: []string{
+			`ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS owned_at TIMESTAMP WITHOUT TIME ZONE`,
+		},
+	},
+}
+
+// Migrate creates the sessions table, its indexes and columns if they don't exist and
+// applies the migrations that haven't been applied yet. Applied versions are recorded
+// in the "<table>_migrations" table. Concurrent calls, eg: from multiple instances
+// of an app, are serialized with an advisory lock.
+func (s *Store) Migrate() error {
+	return migrate(s.db, s.opt.Table, s.opt.Logger)
+}
+
+// EnsureSchema creates or upgrades the schema like Migrate(), but returns without
+// taking the migration lock if the table is already at the latest version. It's
+// idempotent and safe to call on every startup. Opt.AutoMigrate uses it in New().
+func (s *Store) EnsureSchema() error {
+	if v, err := s.SchemaVersion(); err == nil && v >= migrations[len(migrations)-1].version {
+		return nil
+	}
+
+	return s.Migrate()
+}
+
+// SchemaVersion returns the latest migration version applied to the table.
+// 0 is returned if no migrations have been applied.
+func (s *Store) SchemaVersion() (int, error) {
+	var v int
+	err := s.db.QueryRow(fmt.Sprintf("SELECT COALESCE(MAX(version), 0) FROM %s_migrations", s.opt.Table)).Scan(&v)
+	return v, err
+}
+
+func migrate(db *sql.DB, table string, log *slog.Logger) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock for the duration of the transaction so that concurrent migrations wait.
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", "simplesessions:"+table); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s_migrations (
+		version INT NOT NULL PRIMARY KEY,
+		applied_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW() NOT NULL
+	)`, table)); err != nil {
+		return err
+	}
+
+	var version int
+	if err := tx.QueryRow(fmt.Sprintf("SELECT COALESCE(MAX(version), 0) FROM %s_migrations", table)).Scan(&version); err != nil {
+		return err
+	}
+
+	var (
+		name    = sanitizeName(table)
+		applied []int
+	)
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+
+		for _, stmt := range m.stmts {
+			if _, err := tx.Exec(fmt.Sprintf(stmt, table, name)); err != nil {
+				return fmt.Errorf("error applying migration %d: %v", m.version, err)
+			}
+		}
+
+		if _, err := tx.Exec(fmt.Sprintf("INSERT INTO %s_migrations (version) VALUES($1)", table), m.version); err != nil {
+			return err
+		}
+		applied = append(applied, m.version)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if log != nil {
+		for _, v := range applied {
+			log.Info("applied migration", slog.String("table", table), slog.Int("version", v))
+		}
+	}
+
+	return nil
+}
+
+// sanitizeName replaces characters that can't be in unquoted identifiers,
+// eg: the dot in schema qualified table names, with underscores.
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
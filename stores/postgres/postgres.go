@@ -4,41 +4,44 @@ package postgres
 CREATE TABLE sessions (
     id TEXT NOT NULL PRIMARY KEY,
     data jsonb DEFAULT '{}'::jsonb NOT NULL,
-    created_at timestamp without time zone DEFAULT now() NOT NULL
+    created_at timestamp without time zone DEFAULT now() NOT NULL,
+    updated_at timestamp without time zone DEFAULT now() NOT NULL,
+    owner TEXT,
+    owned_at timestamp without time zone
 );
 CREATE INDEX idx_sessions ON sessions (id, created_at);
+CREATE INDEX idx_sessions_updated_at ON sessions (updated_at);
+CREATE INDEX idx_sessions_owner ON sessions (owner);
+
+The updated_at column is only required if Opt.ExtendTTL is enabled and
+the owner and owned_at columns only if SetOwner() and UserSessions() are used.
+Store.EnsureSchema(), Store.Migrate() or Opt.AutoMigrate creates and upgrades the schema.
 */
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/lib/pq"
 	_ "github.com/lib/pq"
+	"github.com/zerodha/simplesessions/v3"
 )
 
 var (
-	// Error codes for store errors. This should match the codes
-	// defined in the /simplesessions package exactly.
-	ErrInvalidSession = &Err{code: 1, msg: "invalid session"}
-	ErrNil            = &Err{code: 2, msg: "nil returned"}
-	ErrAssertType     = &Err{code: 3, msg: "assertion failed"}
+	// Errors returned by the store. Their codes map them to the errors in the
+	// simplesessions package. Errors with an underlying cause wrap it.
+	ErrInvalidSession = simplesessions.NewStoreError(simplesessions.CodeInvalidSession, "invalid session")
+	ErrNil            = simplesessions.NewStoreError(simplesessions.CodeNil, "nil returned")
+	ErrAssertType     = simplesessions.NewStoreError(simplesessions.CodeAssertType, "assertion failed")
 )
 
-type Err struct {
-	code int
-	msg  string
-}
-
-func (e *Err) Error() string {
-	return e.msg
-}
-
-func (e *Err) Code() int {
-	return e.code
-}
+// Err is the type of the errors returned by the store.
+type Err = simplesessions.StoreError
 
 type queries struct {
 	create  *sql.Stmt
@@ -48,6 +51,13 @@ type queries struct {
 	clear   *sql.Stmt
 	prune   *sql.Stmt
 	destroy *sql.Stmt
+	rename  *sql.Stmt
+}
+
+// userQueries are the queries of the user index.
+type userQueries struct {
+	setOwner     *sql.Stmt
+	userSessions *sql.Stmt
 }
 
 // Store represents redis session store for simple sessions.
@@ -56,15 +66,51 @@ type Store struct {
 	db  *sql.DB
 	opt Opt
 	q   *queries
+
+	// Queries of the user index. They're prepared on first use so
+	// that the owner columns are only required if they're used.
+	uq   *userQueries
+	uqMu sync.Mutex
+
+	// Stops the background pruner.
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
 }
 
 type Opt struct {
 	Table string        `json:"table"`
 	TTL   time.Duration `json:"ttl"`
 
+	// ExtendTTL expires sessions TTL after they were last written to instead of
+	// after they were created. Writes update the updated_at column of the session.
+	ExtendTTL bool `json:"extend_ttl"`
+
+	// ExtendTTLOnRead also extends the TTL when the session is read.
+	// This requires ExtendTTL and turns every read into a write.
+	ExtendTTLOnRead bool `json:"extend_ttl_on_read"`
+
 	// Delete expired (TTL) rows from the table at this interval.
-	// This runs concurrently on a separate goroutine.
+	// This runs concurrently on a separate goroutine if AutoPrune is enabled.
 	CleanInterval time.Duration `json:"clean_interval"`
+
+	// AutoPrune runs Prune() on a background goroutine every CleanInterval.
+	// Use Close() to stop it.
+	AutoPrune bool `json:"auto_prune"`
+
+	// Maximum number of expired rows deleted in one query by Prune().
+	// Rows are deleted in batches to avoid locking large tables for long.
+	PruneBatchSize int `json:"prune_batch_size"`
+
+	// OnPrune is called after every background prune with the number
+	// of expired rows deleted and the error, if any.
+	OnPrune func(n int64, err error) `json:"-"`
+
+	// AutoMigrate runs EnsureSchema() in New() to create or upgrade the table.
+	AutoMigrate bool `json:"auto_migrate"`
+
+	// Logger for structured logs of applied migrations and background prunes.
+	// Disabled if nil.
+	Logger *slog.Logger `json:"-"`
 }
 
 // New creates a new Postgres store instance.
@@ -78,12 +124,21 @@ func New(opt Opt, db *sql.DB) (*Store, error) {
 	if opt.CleanInterval.Seconds() < 1 {
 		opt.CleanInterval = time.Hour * 1
 	}
+	if opt.PruneBatchSize < 1 {
+		opt.PruneBatchSize = 1000
+	}
 
 	st := &Store{
 		db:  db,
 		opt: opt,
 	}
 
+	if opt.AutoMigrate {
+		if err := st.EnsureSchema(); err != nil {
+			return nil, err
+		}
+	}
+
 	// Prepare and keep the queries.
 	q, err := st.prepareQueries()
 	if err != nil {
@@ -91,22 +146,46 @@ func New(opt Opt, db *sql.DB) (*Store, error) {
 	}
 	st.q = q
 
+	if opt.AutoPrune {
+		ctx, cancel := context.WithCancel(context.Background())
+		st.cancel = cancel
+		st.wg.Add(1)
+		go st.runPruner(ctx)
+	}
+
 	return st, nil
 }
 
+// Close stops the background pruner, if it's running, and waits for it to exit.
+// The DB isn't closed.
+func (s *Store) Close() error {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+	return nil
+}
+
 // Create creates a new session and returns the ID.
 func (s *Store) Create(id string) error {
-	_, err := s.q.create.Exec(id)
+	return s.CreateCtx(context.Background(), id)
+}
+
+// CreateCtx is the context aware version of Create.
+func (s *Store) CreateCtx(ctx context.Context, id string) error {
+	_, err := s.q.create.ExecContext(ctx, id)
 	return err
 }
 
 // Get returns a single session field's value.
 func (s *Store) Get(id, key string) (interface{}, error) {
-	vals, err := s.GetAll(id)
+	return s.GetCtx(context.Background(), id, key)
+}
+
+// GetCtx is the context aware version of Get.
+func (s *Store) GetCtx(ctx context.Context, id, key string) (interface{}, error) {
+	vals, err := s.GetAllCtx(ctx, id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, ErrInvalidSession
-		}
 		return nil, err
 	}
 
@@ -118,30 +197,39 @@ func (s *Store) Get(id, key string) (interface{}, error) {
 	return v, nil
 }
 
-// GetMulti gets a map for values for multiple keys. If a key doesn't exist, it returns ErrFieldNotFound.
+// GetMulti gets a map for values for multiple keys. If a key doesn't exist, its value is nil.
 func (s *Store) GetMulti(id string, keys ...string) (map[string]interface{}, error) {
-	vals, err := s.GetAll(id)
+	return s.GetMultiCtx(context.Background(), id, keys...)
+}
+
+// GetMultiCtx is the context aware version of GetMulti.
+func (s *Store) GetMultiCtx(ctx context.Context, id string, keys ...string) (map[string]interface{}, error) {
+	vals, err := s.GetAllCtx(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
 	out := make(map[string]interface{}, len(keys))
 	for _, k := range keys {
-		v, ok := vals[k]
-		if !ok {
-			return nil, nil
-		}
-		out[k] = v
+		out[k] = vals[k]
 	}
 
-	return out, err
+	return out, nil
 }
 
 // GetAll returns the map of all keys in the session.
 func (s *Store) GetAll(id string) (map[string]interface{}, error) {
+	return s.GetAllCtx(context.Background(), id)
+}
+
+// GetAllCtx is the context aware version of GetAll.
+func (s *Store) GetAllCtx(ctx context.Context, id string) (map[string]interface{}, error) {
 	var b []byte
-	err := s.q.get.QueryRow(id, s.opt.TTL.Seconds()).Scan(&b)
+	err := s.q.get.QueryRowContext(ctx, id, s.opt.TTL.Seconds()).Scan(&b)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvalidSession.Wrap(err)
+		}
 		return nil, err
 	}
 
@@ -155,13 +243,18 @@ func (s *Store) GetAll(id string) (map[string]interface{}, error) {
 
 // Set sets a value to given session but is stored only on commit.
 func (s *Store) Set(id, key string, val interface{}) (err error) {
+	return s.SetCtx(context.Background(), id, key, val)
+}
+
+// SetCtx is the context aware version of Set.
+func (s *Store) SetCtx(ctx context.Context, id, key string, val interface{}) (err error) {
 	b, err := json.Marshal(map[string]interface{}{key: val})
 	if err != nil {
 		return err
 	}
 
 	// Execute the query in the batch to be committed later.
-	res, err := s.q.update.Exec(id, json.RawMessage(b))
+	res, err := s.q.update.ExecContext(ctx, id, json.RawMessage(b), s.opt.TTL.Seconds())
 	if err != nil {
 		return err
 	}
@@ -170,7 +263,7 @@ func (s *Store) Set(id, key string, val interface{}) (err error) {
 		return err
 	}
 
-	// No row was updated. The session didn't exist.
+	// No row was updated. The session didn't exist or has expired.
 	if num == 0 {
 		return ErrInvalidSession
 	}
@@ -180,13 +273,18 @@ func (s *Store) Set(id, key string, val interface{}) (err error) {
 
 // Set sets a value to given session but is stored only on commit.
 func (s *Store) SetMulti(id string, data map[string]interface{}) (err error) {
+	return s.SetMultiCtx(context.Background(), id, data)
+}
+
+// SetMultiCtx is the context aware version of SetMulti.
+func (s *Store) SetMultiCtx(ctx context.Context, id string, data map[string]interface{}) (err error) {
 	b, err := json.Marshal(data)
 	if err != nil {
 		return err
 	}
 
 	// Execute the query in the batch to be committed later.
-	res, err := s.q.update.Exec(id, json.RawMessage(b))
+	res, err := s.q.update.ExecContext(ctx, id, json.RawMessage(b), s.opt.TTL.Seconds())
 	if err != nil {
 		return err
 	}
@@ -195,7 +293,7 @@ func (s *Store) SetMulti(id string, data map[string]interface{}) (err error) {
 		return err
 	}
 
-	// No row was updated. The session didn't exist.
+	// No row was updated. The session didn't exist or has expired.
 	if num == 0 {
 		return ErrInvalidSession
 	}
@@ -205,7 +303,12 @@ func (s *Store) SetMulti(id string, data map[string]interface{}) (err error) {
 
 // Delete deletes a key from redis session hashmap.
 func (s *Store) Delete(id string, keys ...string) error {
-	res, err := s.q.delete.Exec(id, pq.Array(keys))
+	return s.DeleteCtx(context.Background(), id, keys...)
+}
+
+// DeleteCtx is the context aware version of Delete.
+func (s *Store) DeleteCtx(ctx context.Context, id string, keys ...string) error {
+	res, err := s.q.delete.ExecContext(ctx, id, pq.Array(keys), s.opt.TTL.Seconds())
 	if err != nil {
 		return err
 	}
@@ -215,7 +318,7 @@ func (s *Store) Delete(id string, keys ...string) error {
 		return err
 	}
 
-	// No row was updated. The session didn't exist.
+	// No row was updated. The session didn't exist or has expired.
 	if num == 0 {
 		return ErrInvalidSession
 	}
@@ -225,7 +328,12 @@ func (s *Store) Delete(id string, keys ...string) error {
 
 // Clear clears session in redis.
 func (s *Store) Clear(id string) error {
-	res, err := s.q.clear.Exec(id)
+	return s.ClearCtx(context.Background(), id)
+}
+
+// ClearCtx is the context aware version of Clear.
+func (s *Store) ClearCtx(ctx context.Context, id string) error {
+	res, err := s.q.clear.ExecContext(ctx, id, s.opt.TTL.Seconds())
 	if err != nil {
 		return err
 	}
@@ -235,7 +343,7 @@ func (s *Store) Clear(id string) error {
 		return err
 	}
 
-	// No row was updated. The session didn't exist.
+	// No row was updated. The session didn't exist or has expired.
 	if num == 0 {
 		return ErrInvalidSession
 	}
@@ -245,7 +353,12 @@ func (s *Store) Clear(id string) error {
 
 // Destroy deletes the entire session from backend.
 func (s *Store) Destroy(id string) error {
-	res, err := s.q.destroy.Exec(id)
+	return s.DestroyCtx(context.Background(), id)
+}
+
+// DestroyCtx is the context aware version of Destroy.
+func (s *Store) DestroyCtx(ctx context.Context, id string) error {
+	res, err := s.q.destroy.ExecContext(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -263,12 +376,105 @@ func (s *Store) Destroy(id string) error {
 	return nil
 }
 
+// Rename moves the session to a new ID by updating the ID of the row.
+func (s *Store) Rename(id, newID string) error {
+	return s.RenameCtx(context.Background(), id, newID)
+}
+
+// RenameCtx is the context aware version of Rename.
+func (s *Store) RenameCtx(ctx context.Context, id, newID string) error {
+	res, err := s.q.rename.ExecContext(ctx, id, newID)
+	if err != nil {
+		return err
+	}
+
+	num, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// No row was updated. The session didn't exist.
+	if num == 0 {
+		return ErrInvalidSession
+	}
+
+	return nil
+}
+
+// SetOwner sets the owner of the session, usually a user ID, to list its sessions
+// with UserSessions(). The owner is held in the indexed owner column and the
+// time it was set in owned_at. An empty userID removes the owner. Setting
+// the same owner again doesn't change the order of the session.
+func (s *Store) SetOwner(id, userID string) error {
+	return s.SetOwnerCtx(context.Background(), id, userID)
+}
+
+// SetOwnerCtx is the context aware version of SetOwner.
+func (s *Store) SetOwnerCtx(ctx context.Context, id, userID string) error {
+	uq, err := s.userQueries()
+	if err != nil {
+		return err
+	}
+
+	res, err := uq.setOwner.ExecContext(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+
+	num, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// No row was updated. The session didn't exist.
+	if num == 0 {
+		return ErrInvalidSession
+	}
+
+	return nil
+}
+
+// UserSessions returns the IDs of the sessions owned by the given user that
+// haven't expired, in the order they were assigned to the user, oldest first.
+func (s *Store) UserSessions(userID string) ([]string, error) {
+	return s.UserSessionsCtx(context.Background(), userID)
+}
+
+// UserSessionsCtx is the context aware version of UserSessions.
+func (s *Store) UserSessionsCtx(ctx context.Context, userID string) ([]string, error) {
+	uq, err := s.userQueries()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := uq.userSessions.QueryContext(ctx, userID, s.opt.TTL.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+
+	return out, rows.Err()
+}
+
 // Int is a helper method to type assert as integer.
 func (s *Store) Int(r interface{}, err error) (int, error) {
 	if err != nil {
 		return 0, err
 	}
 
+	if r == nil {
+		return 0, ErrNil
+	}
+
 	v, ok := r.(float64)
 	if !ok {
 		return 0, ErrAssertType
@@ -283,6 +489,10 @@ func (s *Store) Int64(r interface{}, err error) (int64, error) {
 		return 0, err
 	}
 
+	if r == nil {
+		return 0, ErrNil
+	}
+
 	v, ok := r.(float64)
 	if !ok {
 		return 0, ErrAssertType
@@ -297,6 +507,10 @@ func (s *Store) UInt64(r interface{}, err error) (uint64, error) {
 		return 0, err
 	}
 
+	if r == nil {
+		return 0, ErrNil
+	}
+
 	v, ok := r.(float64)
 	if !ok {
 		return 0, ErrAssertType
@@ -311,6 +525,10 @@ func (s *Store) Float64(r interface{}, err error) (float64, error) {
 		return 0, err
 	}
 
+	if r == nil {
+		return 0, ErrNil
+	}
+
 	v, ok := r.(float64)
 	if !ok {
 		return 0, ErrAssertType
@@ -325,6 +543,10 @@ func (s *Store) String(r interface{}, err error) (string, error) {
 		return "", err
 	}
 
+	if r == nil {
+		return "", ErrNil
+	}
+
 	v, ok := r.(string)
 	if !ok {
 		return "", ErrAssertType
@@ -339,6 +561,10 @@ func (s *Store) Bytes(r interface{}, err error) ([]byte, error) {
 		return nil, err
 	}
 
+	if r == nil {
+		return nil, ErrNil
+	}
+
 	v, ok := r.(string)
 	if !ok {
 		return nil, ErrAssertType
@@ -353,6 +579,10 @@ func (s *Store) Bool(r interface{}, err error) (bool, error) {
 		return false, err
 	}
 
+	if r == nil {
+		return false, ErrNil
+	}
+
 	v, ok := r.(bool)
 	if !ok {
 		return false, ErrAssertType
@@ -361,45 +591,112 @@ func (s *Store) Bool(r interface{}, err error) (bool, error) {
 	return v, nil
 }
 
-// Prune deletes rows that have exceeded the TTL. This should be run externally periodically (ideally as a separate goroutine)
+// Prune deletes rows that have exceeded the TTL in batches of Opt.PruneBatchSize.
+// Unless Opt.AutoPrune is enabled, this should be run externally periodically (ideally as a separate goroutine)
 // at desired intervals, hourly/daily etc. based on the expected volume of sessions.
 func (s *Store) Prune() error {
-	_, err := s.q.prune.Exec(s.opt.TTL.Seconds())
+	_, err := s.prune(context.Background())
 	return err
 }
 
+// prune deletes the expired rows in batches until there are none left
+// and returns the number of rows deleted.
+func (s *Store) prune(ctx context.Context) (int64, error) {
+	var total int64
+	for {
+		res, err := s.q.prune.ExecContext(ctx, s.opt.TTL.Seconds(), s.opt.PruneBatchSize)
+		if err != nil {
+			return total, err
+		}
+
+		num, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += num
+
+		if num < int64(s.opt.PruneBatchSize) {
+			return total, nil
+		}
+	}
+}
+
+// runPruner runs prune every CleanInterval until the context is cancelled.
+func (s *Store) runPruner(ctx context.Context) {
+	defer s.wg.Done()
+
+	t := time.NewTicker(s.opt.CleanInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			n, err := s.prune(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			if l := s.opt.Logger; l != nil {
+				if err != nil {
+					l.Error("error pruning sessions", slog.Int64("count", n), slog.Any("error", err))
+				} else if n > 0 {
+					l.Debug("pruned expired sessions", slog.Int64("count", n))
+				}
+			}
+			if s.opt.OnPrune != nil {
+				s.opt.OnPrune(n, err)
+			}
+		}
+	}
+}
+
 func (s *Store) prepareQueries() (*queries, error) {
 	var (
 		q   = &queries{}
 		err error
+
+		// Column the TTL is checked against and the clause to update it on writes.
+		expCol = "created_at"
+		touch  = ""
 	)
+	if s.opt.ExtendTTL {
+		expCol = "updated_at"
+		touch = ", updated_at = NOW()"
+	}
 
 	q.create, err = s.db.Prepare(fmt.Sprintf("INSERT INTO %s (id, data) VALUES($1, '{}'::JSONB)", s.opt.Table))
 	if err != nil {
 		return nil, err
 	}
 
-	q.get, err = s.db.Prepare(fmt.Sprintf("SELECT data FROM %s WHERE id=$1 AND created_at >= NOW() - INTERVAL '1 second' * $2", s.opt.Table))
+	if s.opt.ExtendTTL && s.opt.ExtendTTLOnRead {
+		q.get, err = s.db.Prepare(fmt.Sprintf("UPDATE %s SET updated_at = NOW() WHERE id=$1 AND updated_at >= NOW() - INTERVAL '1 second' * $2 RETURNING data", s.opt.Table))
+	} else {
+		q.get, err = s.db.Prepare(fmt.Sprintf("SELECT data FROM %s WHERE id=$1 AND %s >= NOW() - INTERVAL '1 second' * $2", s.opt.Table, expCol))
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	q.update, err = s.db.Prepare(fmt.Sprintf("UPDATE %s SET data = data || $2::JSONB WHERE id = $1", s.opt.Table))
+	q.update, err = s.db.Prepare(fmt.Sprintf("UPDATE %s SET data = data || $2::JSONB%s WHERE id = $1 AND %s >= NOW() - INTERVAL '1 second' * $3", s.opt.Table, touch, expCol))
 	if err != nil {
 		return nil, err
 	}
 
-	q.delete, err = s.db.Prepare(fmt.Sprintf("UPDATE %s SET data = data #- $2 WHERE id=$1", s.opt.Table))
+	q.delete, err = s.db.Prepare(fmt.Sprintf("UPDATE %s SET data = data - $2::text[]%s WHERE id=$1 AND %s >= NOW() - INTERVAL '1 second' * $3", s.opt.Table, touch, expCol))
 	if err != nil {
 		return nil, err
 	}
 
-	q.clear, err = s.db.Prepare(fmt.Sprintf("UPDATE %s SET data = '{}'::JSONB WHERE id=$1", s.opt.Table))
+	q.clear, err = s.db.Prepare(fmt.Sprintf("UPDATE %s SET data = '{}'::JSONB%s WHERE id=$1 AND %s >= NOW() - INTERVAL '1 second' * $2", s.opt.Table, touch, expCol))
 	if err != nil {
 		return nil, err
 	}
 
-	q.prune, err = s.db.Prepare(fmt.Sprintf("DELETE FROM %s WHERE created_at <= NOW() - INTERVAL '1 second' * $1", s.opt.Table))
+	q.prune, err = s.db.Prepare(fmt.Sprintf(`DELETE FROM %s WHERE id IN (
+		SELECT id FROM %s WHERE %s <= NOW() - INTERVAL '1 second' * $1 LIMIT $2 FOR UPDATE SKIP LOCKED
+	)`, s.opt.Table, s.opt.Table, expCol))
 	if err != nil {
 		return nil, err
 	}
@@ -409,5 +706,45 @@ func (s *Store) prepareQueries() (*queries, error) {
 		return nil, err
 	}
 
+	q.rename, err = s.db.Prepare(fmt.Sprintf("UPDATE %s SET id=$2 WHERE id=$1", s.opt.Table))
+	if err != nil {
+		return nil, err
+	}
+
 	return q, err
 }
+
+// userQueries returns the queries of the user index, preparing them on first use.
+func (s *Store) userQueries() (*userQueries, error) {
+	s.uqMu.Lock()
+	defer s.uqMu.Unlock()
+
+	if s.uq != nil {
+		return s.uq, nil
+	}
+
+	expCol := "created_at"
+	if s.opt.ExtendTTL {
+		expCol = "updated_at"
+	}
+
+	var (
+		q   = &userQueries{}
+		err error
+	)
+	q.setOwner, err = s.db.Prepare(fmt.Sprintf(`UPDATE %s SET
+		owned_at = CASE WHEN owner IS NOT DISTINCT FROM NULLIF($2, '') THEN owned_at ELSE NOW() END,
+		owner = NULLIF($2, '')
+		WHERE id=$1`, s.opt.Table))
+	if err != nil {
+		return nil, err
+	}
+
+	q.userSessions, err = s.db.Prepare(fmt.Sprintf("SELECT id FROM %s WHERE owner=$1 AND %s >= NOW() - INTERVAL '1 second' * $2 ORDER BY owned_at, id", s.opt.Table, expCol))
+	if err != nil {
+		return nil, err
+	}
+
+	s.uq = q
+	return q, nil
+}
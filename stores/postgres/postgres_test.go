@@ -3,17 +3,23 @@ package postgres
 // For this test to run, set env vars: PG_HOST, PG_PORT, PG_USER, PG_PASSWORD, PG_DB.
 
 import (
+	"bytes"
+	"context"
 	"crypto/rand"
 	"database/sql"
 	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"testing"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
+	"github.com/zerodha/simplesessions/v3"
+	"github.com/zerodha/simplesessions/v3/storetest"
 )
 
 const testTable = "sessions"
@@ -55,7 +61,7 @@ func init() {
 		log.Fatal(err)
 	}
 
-	if s, err := New(Opt{TTL: time.Second * 2, Table: testTable}, db); err != nil {
+	if s, err := New(Opt{TTL: time.Second * 2, Table: testTable, AutoMigrate: true}, db); err != nil {
 		log.Fatal(err)
 	} else {
 		st = s
@@ -72,6 +78,82 @@ func TestNew(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestConformance(t *testing.T) {
+	storetest.RunConformance(t, func() simplesessions.Store {
+		return st
+	})
+}
+
+func TestMigrate(t *testing.T) {
+	const table = "sessions_migrate_test"
+	_, err := db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s, %s_migrations", table, table))
+	assert.NoError(t, err)
+
+	// The table doesn't exist.
+	_, err = New(Opt{Table: table}, db)
+	assert.Error(t, err)
+
+	var buf bytes.Buffer
+	s, err := New(Opt{Table: table, AutoMigrate: true, ExtendTTL: true, Logger: slog.New(slog.NewTextHandler(&buf, nil))}, db)
+	assert.NoError(t, err)
+	v, err := s.SchemaVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, len(migrations), v)
+
+	// Applied migrations are logged.
+	for _, m := range migrations {
+		assert.Contains(t, buf.String(), fmt.Sprintf("msg=\"applied migration\" table=%s version=%d", table, m.version))
+	}
+
+	id, _ := generateID()
+	assert.NoError(t, s.Create(id))
+	assert.NoError(t, s.Set(id, "str", "hello 123"))
+
+	// Migrations are idempotent and can run concurrently.
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() { errs <- s.Migrate() }()
+	}
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, <-errs)
+	}
+
+	// EnsureSchema is a no-op on an up to date table.
+	buf.Reset()
+	assert.NoError(t, s.EnsureSchema())
+	assert.Empty(t, buf.String())
+
+	var num int
+	err = db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s_migrations", table)).Scan(&num)
+	assert.NoError(t, err)
+	assert.Equal(t, len(migrations), num)
+
+	// Tables created before migrations existed are upgraded.
+	_, err = db.Exec(fmt.Sprintf("DROP TABLE %s_migrations; ALTER TABLE %s DROP COLUMN updated_at", table, table))
+	assert.NoError(t, err)
+	assert.NoError(t, s.Migrate())
+
+	str, err := s.String(s.Get(id, "str"))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello 123", str)
+
+	// EnsureSchema creates missing tables.
+	_, err = db.Exec(fmt.Sprintf("DROP TABLE %s, %s_migrations", table, table))
+	assert.NoError(t, err)
+	assert.NoError(t, s.EnsureSchema())
+	v, err = s.SchemaVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, len(migrations), v)
+
+	_, err = db.Exec(fmt.Sprintf("DROP TABLE %s, %s_migrations", table, table))
+	assert.NoError(t, err)
+}
+
+func TestSanitizeName(t *testing.T) {
+	assert.Equal(t, "public_sessions", sanitizeName("public.sessions"))
+	assert.Equal(t, "sessions_1", sanitizeName("sessions_1"))
+}
+
 func TestCreate(t *testing.T) {
 	id, _ := generateID()
 	err := st.Create(id)
@@ -249,6 +331,15 @@ func TestAll(t *testing.T) {
 	assert.Nil(t, v)
 	assert.Nil(t, err)
 
+	// Multiple keys are deleted at once.
+	assert.NoError(t, st.SetMulti(id, map[string]interface{}{"del1": "a", "del2": "b", "del3": "c"}))
+	assert.NoError(t, st.Delete(id, "del1", "del2"))
+	vals, err := st.GetMulti(id, "del1", "del2", "del3")
+	assert.NoError(t, err)
+	assert.Nil(t, vals["del1"])
+	assert.Nil(t, vals["del2"])
+	assert.Equal(t, "c", vals["del3"])
+
 	// Clear.
 	assert.ErrorIs(t, st.Clear("unknow_id"), ErrInvalidSession)
 	assert.NoError(t, st.Clear(id))
@@ -263,6 +354,55 @@ func TestAll(t *testing.T) {
 	assert.ErrorIs(t, err, ErrInvalidSession)
 }
 
+func TestBufferedCommit(t *testing.T) {
+	var ck *http.Cookie
+	m := simplesessions.New(simplesessions.Options{EnableBufferedWrites: true})
+	m.UseStore(st)
+	m.SetCookieHooks(func(string, interface{}) (*http.Cookie, error) {
+		return ck, nil
+	}, func(c *http.Cookie, _ interface{}) error {
+		ck = c
+		return nil
+	})
+
+	sess, err := m.NewSession(nil, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, sess.SetMulti(map[string]interface{}{"a": "1", "b": "2", "c": "3"}))
+	assert.NoError(t, sess.Commit())
+
+	// All the buffered deletes are committed with a single Delete().
+	assert.NoError(t, sess.Delete("a", "b"))
+	assert.NoError(t, sess.Commit())
+
+	vals, err := st.GetMulti(sess.ID(), "a", "b", "c")
+	assert.NoError(t, err)
+	assert.Nil(t, vals["a"])
+	assert.Nil(t, vals["b"])
+	assert.Equal(t, "3", vals["c"])
+}
+
+func TestRename(t *testing.T) {
+	assert.ErrorIs(t, st.Rename("invalid", "invalid2"), ErrInvalidSession)
+
+	id, _ := generateID()
+	assert.NoError(t, st.Create(id))
+	assert.NoError(t, st.Set(id, "str", "hello 123"))
+
+	newID, _ := generateID()
+	assert.NoError(t, st.Rename(id, newID))
+
+	_, err := st.GetAll(id)
+	assert.Error(t, err)
+
+	v, err := st.String(st.Get(newID, "str"))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello 123", v)
+	// The context is passed on.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, st.RenameCtx(ctx, newID, id), context.Canceled)
+}
+
 func TestPrune(t *testing.T) {
 	id, _ := generateID()
 
@@ -307,11 +447,101 @@ func TestPrune(t *testing.T) {
 
 }
 
-func TestError(t *testing.T) {
-	err := Err{
-		code: 1,
-		msg:  "test",
+func TestAutoPrune(t *testing.T) {
+	var (
+		pruned = make(chan int64, 10)
+		s, err = New(Opt{
+			TTL:            time.Second,
+			Table:          testTable,
+			CleanInterval:  time.Second,
+			AutoPrune:      true,
+			PruneBatchSize: 2,
+			OnPrune: func(n int64, err error) {
+				assert.NoError(t, err)
+				pruned <- n
+			},
+		}, db)
+	)
+	assert.NoError(t, err)
+
+	// Create more sessions than the batch size.
+	ids := make([]string, 5)
+	for i := range ids {
+		ids[i], _ = generateID()
+		assert.NoError(t, s.Create(ids[i]))
+	}
+
+	// Wait for the sessions to expire and be pruned.
+	count := func() int {
+		var num int
+		err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE id = ANY($1)", testTable), pq.Array(ids)).Scan(&num)
+		assert.NoError(t, err)
+		return num
+	}
+	deadline := time.After(time.Second * 5)
+	for count() > 0 {
+		select {
+		case <-pruned:
+		case <-deadline:
+			t.Fatal("expired sessions weren't pruned")
+		}
 	}
-	assert.Equal(t, 1, err.Code())
-	assert.Equal(t, "test", err.Error())
+
+	// The pruner is stopped.
+	assert.NoError(t, s.Close())
+	assert.NoError(t, s.Close())
+}
+
+func TestExtendTTL(t *testing.T) {
+	s, err := New(Opt{TTL: time.Second * 2, Table: testTable, ExtendTTL: true}, db)
+	assert.NoError(t, err)
+
+	id, _ := generateID()
+	assert.NoError(t, s.Create(id))
+
+	// Writes extend the TTL.
+	time.Sleep(time.Millisecond * 1500)
+	assert.NoError(t, s.Set(id, "str", "hello 123"))
+	time.Sleep(time.Millisecond * 1500)
+	v, err := s.Get(id, "str")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello 123", v)
+
+	// Reads don't.
+	time.Sleep(time.Millisecond * 1000)
+	_, err = s.Get(id, "str")
+	assert.ErrorIs(t, err, ErrInvalidSession)
+
+	// Writes to the expired session fail and don't extend its TTL.
+	assert.ErrorIs(t, s.Set(id, "str", "hello 456"), ErrInvalidSession)
+	assert.ErrorIs(t, s.SetMulti(id, map[string]interface{}{"str": "hello 456"}), ErrInvalidSession)
+	assert.ErrorIs(t, s.Delete(id, "str"), ErrInvalidSession)
+	assert.ErrorIs(t, s.Clear(id), ErrInvalidSession)
+	_, err = s.Get(id, "str")
+	assert.ErrorIs(t, err, ErrInvalidSession)
+
+	// Reads extend the TTL with ExtendTTLOnRead.
+	s, err = New(Opt{TTL: time.Second * 2, Table: testTable, ExtendTTL: true, ExtendTTLOnRead: true}, db)
+	assert.NoError(t, err)
+
+	id, _ = generateID()
+	assert.NoError(t, s.Create(id))
+	for i := 0; i < 3; i++ {
+		time.Sleep(time.Millisecond * 1500)
+		_, err = s.Get(id, "str")
+		assert.NoError(t, err)
+	}
+}
+
+func TestError(t *testing.T) {
+	assert.Equal(t, simplesessions.CodeInvalidSession, ErrInvalidSession.Code())
+	assert.Equal(t, simplesessions.CodeNil, ErrNil.Code())
+	assert.Equal(t, simplesessions.CodeAssertType, ErrAssertType.Code())
+	assert.ErrorIs(t, ErrInvalidSession, simplesessions.ErrInvalidSession)
+
+	// Causes are wrapped.
+	err := ErrInvalidSession.Wrap(errors.New("test"))
+	assert.Equal(t, "invalid session: test", err.Error())
+	assert.ErrorIs(t, err, ErrInvalidSession)
+	assert.EqualError(t, errors.Unwrap(err), "test")
 }
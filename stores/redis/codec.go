@@ -0,0 +1,174 @@
+package redis
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"strconv"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec encodes and decodes composite session values, eg: structs,
+// maps and slices, to be stored in Redis. Use `Store.SetCodec()` to set it.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(b []byte) (interface{}, error)
+}
+
+var (
+	// JSONCodec encodes values as JSON. Structs are decoded as map[string]interface{}
+	// and numbers in them as float64.
+	JSONCodec Codec = jsonCodec{}
+
+	// GobCodec encodes values with encoding/gob. Values are decoded to their original
+	// types, but the types have to be registered with gob.Register().
+	GobCodec Codec = gobCodec{}
+
+	// MsgpackCodec encodes values as MessagePack. Structs are decoded as map[string]interface{}.
+	MsgpackCodec Codec = msgpackCodec{}
+)
+
+// Tagged values are prefixed with the marker and a type tag. Values without
+// the marker were written without a codec and are returned as is.
+const (
+	tagMarker = '\x00'
+
+	tagString  = 's'
+	tagBytes   = 'b'
+	tagInt     = 'i'
+	tagInt64   = 'I'
+	tagUInt64  = 'u'
+	tagFloat64 = 'f'
+	tagBool    = 't'
+	tagNil     = 'n'
+	tagCodec   = 'c'
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(b []byte) (interface{}, error) {
+	var v interface{}
+	err := json.Unmarshal(b, &v)
+	return v, err
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	// Encode as an interface so that the type is retained.
+	err := gob.NewEncoder(&b).Encode(&v)
+	return b.Bytes(), err
+}
+
+func (gobCodec) Unmarshal(b []byte) (interface{}, error) {
+	var v interface{}
+	err := gob.NewDecoder(bytes.NewReader(b)).Decode(&v)
+	return v, err
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(b []byte) (interface{}, error) {
+	var v interface{}
+	err := msgpack.Unmarshal(b, &v)
+	return v, err
+}
+
+// encodeValue encodes the value with a type tag if a codec is set.
+// Primitive types are encoded as strings and the rest with the codec.
+func (s *Store) encodeValue(val interface{}) (interface{}, error) {
+	if s.codec == nil {
+		return val, nil
+	}
+
+	var (
+		tag byte
+		b   []byte
+	)
+	switch v := val.(type) {
+	case string:
+		tag, b = tagString, []byte(v)
+	case []byte:
+		tag, b = tagBytes, v
+	case int:
+		tag, b = tagInt, strconv.AppendInt(nil, int64(v), 10)
+	case int64:
+		tag, b = tagInt64, strconv.AppendInt(nil, v, 10)
+	case uint64:
+		tag, b = tagUInt64, strconv.AppendUint(nil, v, 10)
+	case float64:
+		tag, b = tagFloat64, strconv.AppendFloat(nil, v, 'g', -1, 64)
+	case bool:
+		tag, b = tagBool, strconv.AppendBool(nil, v)
+	case nil:
+		tag = tagNil
+	default:
+		enc, err := s.codec.Marshal(val)
+		if err != nil {
+			return nil, err
+		}
+		tag, b = tagCodec, enc
+	}
+
+	out := make([]byte, 0, len(b)+2)
+	out = append(out, tagMarker, tag)
+	return string(append(out, b...)), nil
+}
+
+// decodeValue decodes a value encoded by encodeValue. Values without a type tag
+// are returned as is.
+func (s *Store) decodeValue(val interface{}) (interface{}, error) {
+	str, ok := val.(string)
+	if !ok || len(str) < 2 || str[0] != tagMarker {
+		return val, nil
+	}
+
+	var (
+		b   = str[2:]
+		out interface{}
+		err error
+	)
+	switch str[1] {
+	case tagString:
+		out = b
+	case tagBytes:
+		out = []byte(b)
+	case tagInt:
+		var n int64
+		n, err = strconv.ParseInt(b, 10, 0)
+		out = int(n)
+	case tagInt64:
+		out, err = strconv.ParseInt(b, 10, 64)
+	case tagUInt64:
+		out, err = strconv.ParseUint(b, 10, 64)
+	case tagFloat64:
+		out, err = strconv.ParseFloat(b, 64)
+	case tagBool:
+		out, err = strconv.ParseBool(b)
+	case tagNil:
+		out = nil
+	case tagCodec:
+		if s.codec == nil {
+			return val, nil
+		}
+		out, err = s.codec.Unmarshal([]byte(b))
+	default:
+		return val, nil
+	}
+
+	if err != nil {
+		return nil, ErrAssertType.Wrap(err)
+	}
+
+	return out, nil
+}
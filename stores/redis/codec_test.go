@@ -0,0 +1,116 @@
+package redis
+
+import (
+	"context"
+	"encoding/gob"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type codecTestUser struct {
+	Name  string
+	Roles []string
+}
+
+func init() {
+	gob.Register(codecTestUser{})
+}
+
+func TestCodecPrimitives(t *testing.T) {
+	var (
+		id  = "testid_codec"
+		str = New(context.TODO(), getRedisClient())
+	)
+	str.SetCodec(JSONCodec)
+	assert.NoError(t, str.Create(id))
+
+	data := map[string]interface{}{
+		"str":     "val",
+		"bytes":   []byte("val"),
+		"int":     10,
+		"int64":   int64(-10),
+		"uint64":  uint64(10),
+		"float64": 1.5,
+		"bool":    true,
+		"nil":     nil,
+		// Strings that look like tagged values are retained.
+		"marker": "\x00i10",
+	}
+	assert.NoError(t, str.SetMulti(id, data))
+
+	vals, err := str.GetAll(id)
+	assert.NoError(t, err)
+	assert.Equal(t, data, vals)
+
+	vals, err = str.GetMulti(id, "int", "bool", "unknown")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"int": 10, "bool": true, "unknown": nil}, vals)
+
+	assert.NoError(t, str.Set(id, "int", 20))
+	v, err := str.Get(id, "int")
+	assert.NoError(t, err)
+	assert.Equal(t, 20, v)
+
+	// Helpers work with tagged and untagged values.
+	n, err := str.Int(str.Get(id, "int"))
+	assert.NoError(t, err)
+	assert.Equal(t, 20, n)
+
+	mockRedis.HSet(str.prefix+id, "legacy", "30")
+	n, err = str.Int(str.Get(id, "legacy"))
+	assert.NoError(t, err)
+	assert.Equal(t, 30, n)
+
+	// Malformed tagged values.
+	mockRedis.HSet(str.prefix+id, "invalid", "\x00iabc")
+	_, err = str.Get(id, "invalid")
+	assert.ErrorIs(t, err, ErrAssertType)
+}
+
+func TestCodecs(t *testing.T) {
+	user := codecTestUser{Name: "user", Roles: []string{"admin"}}
+
+	for name, c := range map[string]struct {
+		codec Codec
+		exp   interface{}
+	}{
+		"json":    {JSONCodec, map[string]interface{}{"Name": "user", "Roles": []interface{}{"admin"}}},
+		"gob":     {GobCodec, user},
+		"msgpack": {MsgpackCodec, map[string]interface{}{"Name": "user", "Roles": []interface{}{"admin"}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var (
+				id  = "testid_codec_" + name
+				str = New(context.TODO(), getRedisClient())
+			)
+			str.SetCodec(c.codec)
+			assert.NoError(t, str.Create(id))
+			assert.NoError(t, str.Set(id, "user", user))
+
+			v, err := str.Get(id, "user")
+			assert.NoError(t, err)
+			assert.Equal(t, c.exp, v)
+
+			// Without a codec, composite values are returned as is.
+			str.SetCodec(nil)
+			v, err = str.Get(id, "user")
+			assert.NoError(t, err)
+			assert.IsType(t, "", v)
+		})
+	}
+}
+
+func TestNoCodec(t *testing.T) {
+	var (
+		id  = "testid_nocodec"
+		str = New(context.TODO(), getRedisClient())
+	)
+	assert.NoError(t, str.Create(id))
+	assert.NoError(t, str.Set(id, "int", 10))
+
+	// Values are returned as strings without a codec.
+	v, err := str.Get(id, "int")
+	assert.NoError(t, err)
+	assert.Equal(t, "10", v)
+}
@@ -2,32 +2,24 @@ package redis
 
 import (
 	"context"
+	"log/slog"
 	"strconv"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/zerodha/simplesessions/v3"
 )
 
 var (
-	// Error codes for store errors. This should match the codes
-	// defined in the /simplesessions package exactly.
-	ErrInvalidSession = &Err{code: 1, msg: "invalid session"}
-	ErrNil            = &Err{code: 2, msg: "nil returned"}
-	ErrAssertType     = &Err{code: 3, msg: "assertion failed"}
+	// Errors returned by the store. Their codes map them to the errors in the
+	// simplesessions package. Errors with an underlying cause wrap it.
+	ErrInvalidSession = simplesessions.NewStoreError(simplesessions.CodeInvalidSession, "invalid session")
+	ErrNil            = simplesessions.NewStoreError(simplesessions.CodeNil, "nil returned")
+	ErrAssertType     = simplesessions.NewStoreError(simplesessions.CodeAssertType, "assertion failed")
 )
 
-type Err struct {
-	code int
-	msg  string
-}
-
-func (e *Err) Error() string {
-	return e.msg
-}
-
-func (e *Err) Code() int {
-	return e.code
-}
+// Err is the type of the errors returned by the store.
+type Err = simplesessions.StoreError
 
 // Store represents redis session store for simple sessions.
 // Each session is stored as redis hashmap.
@@ -40,9 +32,16 @@ type Store struct {
 	// Prefix for session id.
 	prefix string
 
+	// Codec for encoding values with their types. If nil, values are
+	// written as is and returned as strings.
+	codec Codec
+
 	// Redis client
 	client    redis.UniversalClient
 	clientCtx context.Context
+
+	// Logger for decode failures and stale index entries. Disabled if nil.
+	log *slog.Logger
 }
 
 const (
@@ -51,9 +50,16 @@ const (
 	// Default key used when session is created.
 	// Its not possible to have empty map in Redis.
 	defaultSessKey = "_ss"
+	// Key in the session that holds its owner.
+	ownerKey = "_ss_owner"
+	// Prefix of the sorted sets that index the session IDs of each owner,
+	// after the session prefix. IDs are scored by when the owner was set.
+	userPrefix = "user:"
 )
 
 // New creates a new Redis store instance.
+// ctx is used for the store methods that don't take a context, eg: Get().
+// Use the *Ctx methods, eg: GetCtx(), to pass a request context.
 func New(ctx context.Context, client redis.UniversalClient) *Store {
 	return &Store{
 		clientCtx: ctx,
@@ -67,6 +73,22 @@ func (s *Store) SetPrefix(val string) {
 	s.prefix = val
 }
 
+// SetCodec sets the codec used to encode session values. With a codec set, values
+// are stored with a type tag and Get/GetMulti/GetAll return them with their original
+// types, eg: int, bool, []byte. Primitive types are encoded as strings and the rest,
+// eg: structs, with the codec. Values written without a codec are returned as strings.
+// Use one of the built-ins, `JSONCodec`, `GobCodec` or `MsgpackCodec`.
+func (s *Store) SetCodec(c Codec) {
+	s.codec = c
+}
+
+// SetLogger sets the logger for structured logs of values that fail to decode and
+// stale sessions removed from the user index. Session IDs are redacted with
+// simplesessions.RedactID(). nil disables logging, which is the default.
+func (s *Store) SetLogger(l *slog.Logger) {
+	s.log = l
+}
+
 // SetTTL sets TTL for session in redis.
 // if isExtend is true then ttl is updated on all set/setmulti.
 // otherwise its set only on create().
@@ -77,21 +99,31 @@ func (s *Store) SetTTL(d time.Duration, extend bool) {
 
 // Create returns a new session id but doesn't stores it in redis since empty hashmap can't be created.
 func (s *Store) Create(id string) error {
+	return s.CreateCtx(s.clientCtx, id)
+}
+
+// CreateCtx is the context aware version of Create.
+func (s *Store) CreateCtx(ctx context.Context, id string) error {
 	// Create the session in backend with default session key since
 	// Redis doesn't support empty hashmap and its impossible to
 	// check if the session exist or not.
 	p := s.client.TxPipeline()
-	p.HSet(s.clientCtx, s.prefix+id, defaultSessKey, "1")
+	p.HSet(ctx, s.prefix+id, defaultSessKey, "1")
 	if s.ttl > 0 {
-		p.Expire(s.clientCtx, s.prefix+id, s.ttl)
+		p.Expire(ctx, s.prefix+id, s.ttl)
 	}
-	_, err := p.Exec(s.clientCtx)
+	_, err := p.Exec(ctx)
 	return err
 }
 
 // Get gets a field in hashmap. If field is nill then ErrFieldNotFound is raised
 func (s *Store) Get(id, key string) (interface{}, error) {
-	vals, err := s.client.HMGet(s.clientCtx, s.prefix+id, defaultSessKey, key).Result()
+	return s.GetCtx(s.clientCtx, id, key)
+}
+
+// GetCtx is the context aware version of Get.
+func (s *Store) GetCtx(ctx context.Context, id, key string) (interface{}, error) {
+	vals, err := s.client.HMGet(ctx, s.prefix+id, defaultSessKey, key).Result()
 	if err != nil {
 		return nil, err
 	}
@@ -100,13 +132,18 @@ func (s *Store) Get(id, key string) (interface{}, error) {
 		return nil, ErrInvalidSession
 	}
 
-	return vals[1], nil
+	return s.decode(ctx, id, key, vals[1])
 }
 
 // GetMulti gets a map for values for multiple keys. If key is not found then its set as nil.
 func (s *Store) GetMulti(id string, keys ...string) (map[string]interface{}, error) {
+	return s.GetMultiCtx(s.clientCtx, id, keys...)
+}
+
+// GetMultiCtx is the context aware version of GetMulti.
+func (s *Store) GetMultiCtx(ctx context.Context, id string, keys ...string) (map[string]interface{}, error) {
 	allKeys := append([]string{defaultSessKey}, keys...)
-	vals, err := s.client.HMGet(s.clientCtx, s.prefix+id, allKeys...).Result()
+	vals, err := s.client.HMGet(ctx, s.prefix+id, allKeys...).Result()
 	if err != nil {
 		return nil, err
 	}
@@ -119,25 +156,39 @@ func (s *Store) GetMulti(id string, keys ...string) (map[string]interface{}, err
 	res := make(map[string]interface{})
 	for i, k := range allKeys {
 		if k != defaultSessKey {
-			res[k] = vals[i]
+			if res[k], err = s.decode(ctx, id, k, vals[i]); err != nil {
+				return nil, err
+			}
 		}
 	}
 
-	return res, err
+	return res, nil
 }
 
 // GetAll gets all fields from hashmap.
 func (s *Store) GetAll(id string) (map[string]interface{}, error) {
-	vals, err := s.client.HGetAll(s.clientCtx, s.prefix+id).Result()
+	return s.GetAllCtx(s.clientCtx, id)
+}
+
+// GetAllCtx is the context aware version of GetAll.
+func (s *Store) GetAllCtx(ctx context.Context, id string) (map[string]interface{}, error) {
+	vals, err := s.client.HGetAll(ctx, s.prefix+id).Result()
 	if err != nil {
 		return nil, err
 	}
 
+	// Session doesn't exist.
+	if _, ok := vals[defaultSessKey]; !ok {
+		return nil, ErrInvalidSession
+	}
+
 	// Convert results to type `map[string]interface{}`
 	out := make(map[string]interface{})
 	for k, v := range vals {
-		if k != defaultSessKey {
-			out[k] = v
+		if k != defaultSessKey && k != ownerKey {
+			if out[k], err = s.decode(ctx, id, k, v); err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -147,62 +198,296 @@ func (s *Store) GetAll(id string) (map[string]interface{}, error) {
 // Set sets a value to given session.
 // If session is not present in backend then its still written.
 func (s *Store) Set(id, key string, val interface{}) error {
+	return s.SetCtx(s.clientCtx, id, key, val)
+}
+
+// SetCtx is the context aware version of Set.
+func (s *Store) SetCtx(ctx context.Context, id, key string, val interface{}) error {
+	val, err := s.encodeValue(val)
+	if err != nil {
+		return err
+	}
+
 	p := s.client.TxPipeline()
-	p.HSet(s.clientCtx, s.prefix+id, key, val)
-	p.HSet(s.clientCtx, s.prefix+id, defaultSessKey, "1")
+	p.HSet(ctx, s.prefix+id, key, val)
+	p.HSet(ctx, s.prefix+id, defaultSessKey, "1")
 
 	// Set expiry of key only if 'ttl' is set, this is to
 	// ensure that the key remains valid indefinitely like
 	// how redis handles it by default
 	if s.ttl > 0 && s.extendTTL {
-		p.Expire(s.clientCtx, s.prefix+id, s.ttl)
+		p.Expire(ctx, s.prefix+id, s.ttl)
 	}
 
-	_, err := p.Exec(s.clientCtx)
+	_, err = p.Exec(ctx)
 	return err
 }
 
 // Set sets a value to given session.
 func (s *Store) SetMulti(id string, data map[string]interface{}) error {
+	return s.SetMultiCtx(s.clientCtx, id, data)
+}
+
+// SetMultiCtx is the context aware version of SetMulti.
+func (s *Store) SetMultiCtx(ctx context.Context, id string, data map[string]interface{}) error {
 	// Make slice of arguments to be passed in HGETALL command
 	args := []interface{}{defaultSessKey, "1"}
 	for k, v := range data {
+		v, err := s.encodeValue(v)
+		if err != nil {
+			return err
+		}
 		args = append(args, k, v)
 	}
 
 	p := s.client.TxPipeline()
-	p.HMSet(s.clientCtx, s.prefix+id, args...)
+	p.HMSet(ctx, s.prefix+id, args...)
 	// Set expiry of key only if 'ttl' is set, this is to
 	// ensure that the key remains valid indefinitely like
 	// how redis handles it by default
 	if s.ttl > 0 && s.extendTTL {
-		p.Expire(s.clientCtx, s.prefix+id, s.ttl)
+		p.Expire(ctx, s.prefix+id, s.ttl)
 	}
 
-	_, err := p.Exec(s.clientCtx)
+	_, err := p.Exec(ctx)
 	return err
 }
 
 // Delete deletes a key from redis session hashmap.
 func (s *Store) Delete(id string, keys ...string) error {
-	return s.client.HDel(s.clientCtx, s.prefix+id, keys...).Err()
+	return s.DeleteCtx(s.clientCtx, id, keys...)
+}
+
+// DeleteCtx is the context aware version of Delete.
+func (s *Store) DeleteCtx(ctx context.Context, id string, keys ...string) error {
+	return s.client.HDel(ctx, s.prefix+id, keys...).Err()
 }
 
 // Clear clears session in redis.
 func (s *Store) Clear(id string) error {
+	return s.ClearCtx(s.clientCtx, id)
+}
+
+// ClearCtx is the context aware version of Clear.
+// The owner of the session is retained.
+func (s *Store) ClearCtx(ctx context.Context, id string) error {
+	owner, err := s.owner(ctx, id)
+	if err != nil {
+		return err
+	}
+
 	p := s.client.TxPipeline()
-	p.Del(s.clientCtx, s.prefix+id).Err()
-	p.HSet(s.clientCtx, s.prefix+id, defaultSessKey, "1")
+	p.Del(ctx, s.prefix+id)
+	p.HSet(ctx, s.prefix+id, defaultSessKey, "1")
+	if owner != "" {
+		p.HSet(ctx, s.prefix+id, ownerKey, owner)
+	}
 	if s.ttl > 0 {
-		p.Expire(s.clientCtx, s.prefix+id, s.ttl)
+		p.Expire(ctx, s.prefix+id, s.ttl)
 	}
-	_, err := p.Exec(s.clientCtx)
+	_, err = p.Exec(ctx)
 	return err
 }
 
 // Destroy deletes the entire session from backend.
 func (s *Store) Destroy(id string) error {
-	return s.client.Del(s.clientCtx, s.prefix+id).Err()
+	return s.DestroyCtx(s.clientCtx, id)
+}
+
+// DestroyCtx is the context aware version of Destroy.
+// The session is also removed from the sessions of its owner.
+// ErrInvalidSession is returned if the session doesn't exist, eg: it has expired.
+func (s *Store) DestroyCtx(ctx context.Context, id string) error {
+	owner, err := s.owner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	var del *redis.IntCmd
+	if owner == "" {
+		del = s.client.Del(ctx, s.prefix+id)
+	} else {
+		p := s.client.TxPipeline()
+		del = p.Del(ctx, s.prefix+id)
+		p.ZRem(ctx, s.prefix+userPrefix+owner, id)
+		if _, err := p.Exec(ctx); err != nil {
+			return err
+		}
+	}
+
+	n, err := del.Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvalidSession
+	}
+
+	return nil
+}
+
+// Rename atomically moves the session to a new ID using RENAME.
+// The TTL and the owner of the session are retained.
+func (s *Store) Rename(id, newID string) error {
+	return s.RenameCtx(s.clientCtx, id, newID)
+}
+
+// RenameCtx is the context aware version of Rename.
+func (s *Store) RenameCtx(ctx context.Context, id, newID string) error {
+	owner, err := s.owner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// Retain the position of the session in the owner's sessions.
+	var (
+		key   = s.prefix + userPrefix + owner
+		score float64
+	)
+	if owner != "" {
+		score, err = s.client.ZScore(ctx, key, id).Result()
+		if err == redis.Nil {
+			score = ownerScore()
+		} else if err != nil {
+			return err
+		}
+	}
+
+	// Rename before updating the index as a failed RENAME in a transaction
+	// doesn't stop the rest of it and would index the new ID regardless.
+	if err := s.client.Rename(ctx, s.prefix+id, s.prefix+newID).Err(); err != nil {
+		if redis.HasErrorPrefix(err, "no such key") {
+			return ErrInvalidSession.Wrap(err)
+		}
+		return err
+	}
+	if owner == "" {
+		return nil
+	}
+
+	p := s.client.TxPipeline()
+	p.ZRem(ctx, key, id)
+	p.ZAdd(ctx, key, redis.Z{Score: score, Member: newID})
+	_, err = p.Exec(ctx)
+	return err
+}
+
+// SetOwner sets the owner of the session, usually a user ID, to list its sessions
+// with UserSessions(). The session IDs of each owner are indexed in a Redis sorted
+// set, scored by when the owner was set. An empty userID removes the owner.
+// Setting the same owner again doesn't change the order of the session.
+func (s *Store) SetOwner(id, userID string) error {
+	return s.SetOwnerCtx(s.clientCtx, id, userID)
+}
+
+// SetOwnerCtx is the context aware version of SetOwner.
+func (s *Store) SetOwnerCtx(ctx context.Context, id, userID string) error {
+	vals, err := s.client.HMGet(ctx, s.prefix+id, defaultSessKey, ownerKey).Result()
+	if err != nil {
+		return err
+	}
+	if vals[0] == nil {
+		return ErrInvalidSession
+	}
+
+	old, _ := vals[1].(string)
+	if old == userID {
+		return nil
+	}
+
+	p := s.client.TxPipeline()
+	if old != "" {
+		p.ZRem(ctx, s.prefix+userPrefix+old, id)
+	}
+	if userID == "" {
+		p.HDel(ctx, s.prefix+id, ownerKey)
+	} else {
+		p.HSet(ctx, s.prefix+id, ownerKey, userID)
+		p.ZAdd(ctx, s.prefix+userPrefix+userID, redis.Z{Score: ownerScore(), Member: id})
+	}
+	_, err = p.Exec(ctx)
+	return err
+}
+
+// UserSessions returns the IDs of the sessions owned by the given user, in the order
+// they were assigned to the user, oldest first. Sessions that have expired since
+// they were indexed are removed from the index.
+func (s *Store) UserSessions(userID string) ([]string, error) {
+	return s.UserSessionsCtx(s.clientCtx, userID)
+}
+
+// UserSessionsCtx is the context aware version of UserSessions.
+func (s *Store) UserSessionsCtx(ctx context.Context, userID string) ([]string, error) {
+	key := s.prefix + userPrefix + userID
+	ids, err := s.client.ZRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return []string{}, nil
+	}
+
+	// Check which of the sessions still exist.
+	p := s.client.Pipeline()
+	cmds := make([]*redis.IntCmd, len(ids))
+	for i, id := range ids {
+		cmds[i] = p.Exists(ctx, s.prefix+id)
+	}
+	if _, err := p.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	var (
+		out   = make([]string, 0, len(ids))
+		stale []interface{}
+	)
+	for i, id := range ids {
+		if cmds[i].Val() > 0 {
+			out = append(out, id)
+		} else {
+			stale = append(stale, id)
+		}
+	}
+
+	if len(stale) > 0 {
+		if err := s.client.ZRem(ctx, key, stale...).Err(); err != nil {
+			return nil, err
+		}
+		if s.log != nil {
+			s.log.DebugContext(ctx, "removed stale sessions from user index", slog.Int("count", len(stale)))
+		}
+	}
+
+	return out, nil
+}
+
+// decode decodes the value of the key in the session and logs the error if it fails.
+func (s *Store) decode(ctx context.Context, id, key string, val interface{}) (interface{}, error) {
+	v, err := s.decodeValue(val)
+	if err != nil && s.log != nil {
+		s.log.WarnContext(ctx, "error decoding session value",
+			slog.String("session_id", simplesessions.RedactID(id)),
+			slog.String("key", key),
+			slog.Any("error", err))
+	}
+
+	return v, err
+}
+
+// ownerScore returns the score of a session in the owner's sorted set, the current
+// time in microseconds, which float64 scores can hold exactly.
+func ownerScore() float64 {
+	return float64(time.Now().UnixMicro())
+}
+
+// owner returns the owner of the session. It's empty if the session
+// doesn't have an owner or doesn't exist.
+func (s *Store) owner(ctx context.Context, id string) (string, error) {
+	v, err := s.client.HGet(ctx, s.prefix+id, ownerKey).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return v, err
 }
 
 // Int converts interface to integer.
@@ -222,13 +507,13 @@ func (s *Store) Int(r interface{}, err error) (int, error) {
 		}
 	case []byte:
 		if n, err := strconv.ParseInt(string(r), 10, 0); err != nil {
-			return 0, ErrAssertType
+			return 0, ErrAssertType.Wrap(err)
 		} else {
 			return int(n), nil
 		}
 	case string:
 		if n, err := strconv.ParseInt(r, 10, 0); err != nil {
-			return 0, ErrAssertType
+			return 0, ErrAssertType.Wrap(err)
 		} else {
 			return int(n), nil
 		}
@@ -254,13 +539,13 @@ func (s *Store) Int64(r interface{}, err error) (int64, error) {
 		return r, nil
 	case []byte:
 		if n, err := strconv.ParseInt(string(r), 10, 64); err != nil {
-			return 0, ErrAssertType
+			return 0, ErrAssertType.Wrap(err)
 		} else {
 			return n, nil
 		}
 	case string:
 		if n, err := strconv.ParseInt(r, 10, 64); err != nil {
-			return 0, ErrAssertType
+			return 0, ErrAssertType.Wrap(err)
 		} else {
 			return n, nil
 		}
@@ -294,13 +579,13 @@ func (s *Store) UInt64(r interface{}, err error) (uint64, error) {
 		return uint64(r), nil
 	case []byte:
 		if n, err := strconv.ParseUint(string(r), 10, 64); err != nil {
-			return 0, ErrAssertType
+			return 0, ErrAssertType.Wrap(err)
 		} else {
 			return n, nil
 		}
 	case string:
 		if n, err := strconv.ParseUint(r, 10, 64); err != nil {
-			return 0, ErrAssertType
+			return 0, ErrAssertType.Wrap(err)
 		} else {
 			return n, nil
 		}
@@ -323,13 +608,13 @@ func (s *Store) Float64(r interface{}, err error) (float64, error) {
 		return r, err
 	case []byte:
 		if n, err := strconv.ParseFloat(string(r), 64); err != nil {
-			return 0, ErrAssertType
+			return 0, ErrAssertType.Wrap(err)
 		} else {
 			return n, nil
 		}
 	case string:
 		if n, err := strconv.ParseFloat(r, 64); err != nil {
-			return 0, ErrAssertType
+			return 0, ErrAssertType.Wrap(err)
 		} else {
 			return n, nil
 		}
@@ -392,13 +677,13 @@ func (s *Store) Bool(r interface{}, err error) (bool, error) {
 		return r != 0, nil
 	case []byte:
 		if n, err := strconv.ParseBool(string(r)); err != nil {
-			return false, ErrAssertType
+			return false, ErrAssertType.Wrap(err)
 		} else {
 			return n, nil
 		}
 	case string:
 		if n, err := strconv.ParseBool(r); err != nil {
-			return false, ErrAssertType
+			return false, ErrAssertType.Wrap(err)
 		} else {
 			return n, nil
 		}
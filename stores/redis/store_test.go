@@ -1,14 +1,19 @@
 package redis
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"log/slog"
+	"net/http"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
+	"github.com/zerodha/simplesessions/v3"
+	"github.com/zerodha/simplesessions/v3/storetest"
 )
 
 var (
@@ -39,6 +44,12 @@ func TestNew(t *testing.T) {
 	assert.Equal(t, str.clientCtx, ctx)
 }
 
+func TestConformance(t *testing.T) {
+	storetest.RunConformance(t, func() simplesessions.Store {
+		return New(context.TODO(), getRedisClient())
+	})
+}
+
 func TestSetPrefix(t *testing.T) {
 	str := New(context.TODO(), getRedisClient())
 	str.SetPrefix("test")
@@ -308,6 +319,208 @@ func TestDestroy(t *testing.T) {
 	val, err := client.Exists(context.TODO(), str.prefix+key).Result()
 	assert.NoError(t, err)
 	assert.Equal(t, val, int64(0))
+	// Sessions that don't exist, eg: expired ones, aren't counted as destroyed.
+	assert.ErrorIs(t, str.Destroy(key), ErrInvalidSession)
+
+	// Including the ones that are still in their owner's index.
+	assert.NoError(t, str.Create(key))
+	assert.NoError(t, str.SetOwner(key, "testuser_destroy"))
+	assert.NoError(t, client.Del(context.TODO(), str.prefix+key).Err())
+	assert.ErrorIs(t, str.Destroy(key), ErrInvalidSession)
+}
+
+func TestDestroyExpired(t *testing.T) {
+	var (
+		str = New(context.TODO(), getRedisClient())
+		m   = simplesessions.New(simplesessions.Options{})
+		ck  *http.Cookie
+	)
+	str.SetTTL(time.Second, false)
+	m.UseStore(str)
+	m.SetCookieHooks(func(string, interface{}) (*http.Cookie, error) {
+		return ck, nil
+	}, func(c *http.Cookie, _ interface{}) error {
+		ck = c
+		return nil
+	})
+
+	sess, err := m.NewSession(nil, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, sess.Set("key1", "val1"))
+	mockRedis.FastForward(time.Second * 2)
+
+	// Logging out of an expired session clears the cookie.
+	sess, err = m.Acquire(context.Background(), nil, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, sess.Destroy())
+	assert.Empty(t, ck.Value)
+	assert.Greater(t, time.Now(), ck.Expires)
+}
+
+func TestRename(t *testing.T) {
+	var (
+		client = getRedisClient()
+		str    = New(context.TODO(), client)
+
+		// this key is unique across all tests
+		key    = "testid_rename"
+		newKey = "testid_renamed"
+		field1 = "somekey"
+		value1 = 100
+	)
+
+	err := str.Rename("invalidkey", newKey)
+	assert.ErrorIs(t, ErrInvalidSession, err)
+
+	err = client.HMSet(context.TODO(), str.prefix+key, defaultSessKey, "1", field1, value1).Err()
+	assert.NoError(t, err)
+
+	err = str.Rename(key, newKey)
+	assert.NoError(t, err)
+
+	val, err := client.Exists(context.TODO(), str.prefix+key).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, val, int64(0))
+
+	v, err := str.Int(str.Get(newKey, field1))
+	assert.NoError(t, err)
+	assert.Equal(t, value1, v)
+}
+
+// expireHook deletes the key of a session right before it's renamed.
+type expireHook struct {
+	client redis.UniversalClient
+}
+
+func (h expireHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h expireHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if cmd.Name() == "rename" {
+			h.client.Del(ctx, cmd.Args()[1].(string))
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (h expireHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			if cmd.Name() == "rename" {
+				h.client.Del(ctx, cmd.Args()[1].(string))
+			}
+		}
+		return next(ctx, cmds)
+	}
+}
+
+func TestRenameExpired(t *testing.T) {
+	var (
+		client = getRedisClient()
+		str    = New(context.TODO(), client)
+		user   = "testuser_rename_expired"
+		id     = "testid_rename_expired"
+	)
+	assert.NoError(t, str.Create(id))
+	assert.NoError(t, str.SetOwner(id, user))
+
+	// The session expires after its owner is read. The new ID isn't indexed.
+	client.AddHook(expireHook{getRedisClient()})
+	assert.ErrorIs(t, str.Rename(id, id+"_new"), ErrInvalidSession)
+
+	ids, err := getRedisClient().ZRange(context.TODO(), str.prefix+userPrefix+user, 0, -1).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{id}, ids)
+}
+
+func TestUserSessions(t *testing.T) {
+	var (
+		client = getRedisClient()
+		str    = New(context.TODO(), client)
+		user   = "testuser_sessions"
+	)
+
+	for _, id := range []string{"testid_user1", "testid_user2"} {
+		assert.NoError(t, str.Create(id))
+		assert.NoError(t, str.SetOwner(id, user))
+	}
+
+	// The owner is held in the session but isn't returned with its values.
+	owner, err := client.HGet(context.TODO(), str.prefix+"testid_user1", ownerKey).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, user, owner)
+
+	all, err := str.GetAll("testid_user1")
+	assert.NoError(t, err)
+	assert.Empty(t, all)
+
+	// Sessions that have expired are removed from the index.
+	assert.NoError(t, client.Del(context.TODO(), str.prefix+"testid_user1").Err())
+	ids, err := str.UserSessions(user)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"testid_user2"}, ids)
+
+	ids, err = client.ZRange(context.TODO(), str.prefix+userPrefix+user, 0, -1).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"testid_user2"}, ids)
+}
+
+func TestLogger(t *testing.T) {
+	var (
+		buf    bytes.Buffer
+		client = getRedisClient()
+		str    = New(context.TODO(), client)
+		id     = "testid_logger"
+	)
+	str.SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	// Values that fail to decode are logged with the redacted ID.
+	err := client.HSet(context.TODO(), str.prefix+id, defaultSessKey, "1", "num", string([]byte{tagMarker, tagInt})+"x").Err()
+	assert.NoError(t, err)
+
+	_, err = str.Get(id, "num")
+	assert.Error(t, err)
+	assert.Contains(t, buf.String(), "level=WARN msg=\"error decoding session value\" session_id=test*** key=num")
+	assert.NotContains(t, buf.String(), id)
+
+	// Stale sessions removed from the user index are logged.
+	buf.Reset()
+	assert.NoError(t, str.SetOwner(id, "testuser_logger"))
+	assert.NoError(t, client.Del(context.TODO(), str.prefix+id).Err())
+	ids, err := str.UserSessions("testuser_logger")
+	assert.NoError(t, err)
+	assert.Empty(t, ids)
+	assert.Contains(t, buf.String(), "msg=\"removed stale sessions from user index\" count=1")
+}
+
+func TestContext(t *testing.T) {
+	var (
+		client = getRedisClient()
+		str    = New(context.TODO(), client)
+
+		// this key is unique across all tests
+		key = "testid_context"
+	)
+
+	assert.NoError(t, str.CreateCtx(context.Background(), key))
+	assert.NoError(t, str.SetCtx(context.Background(), key, "foo", "bar"))
+
+	v, err := str.String(str.GetCtx(context.Background(), key, "foo"))
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", v)
+
+	// Request context is used over the store's context.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = str.GetCtx(ctx, key, "foo")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, str.SetCtx(ctx, key, "foo", "baz"), context.Canceled)
+	assert.ErrorIs(t, str.RenameCtx(ctx, key, key+"_new"), context.Canceled)
+	assert.ErrorIs(t, str.SetOwnerCtx(ctx, key, "testuser_context"), context.Canceled)
+	_, err = str.UserSessionsCtx(ctx, "testuser_context")
+	assert.ErrorIs(t, err, context.Canceled)
 }
 
 func TestInt(t *testing.T) {
@@ -583,10 +796,14 @@ func TestBool(t *testing.T) {
 }
 
 func TestError(t *testing.T) {
-	err := Err{
-		code: 1,
-		msg:  "test",
-	}
-	assert.Equal(t, 1, err.Code())
-	assert.Equal(t, "test", err.Error())
+	assert.Equal(t, simplesessions.CodeInvalidSession, ErrInvalidSession.Code())
+	assert.Equal(t, simplesessions.CodeNil, ErrNil.Code())
+	assert.Equal(t, simplesessions.CodeAssertType, ErrAssertType.Code())
+	assert.ErrorIs(t, ErrInvalidSession, simplesessions.ErrInvalidSession)
+
+	// Causes are wrapped.
+	err := ErrInvalidSession.Wrap(errors.New("test"))
+	assert.Equal(t, "invalid session: test", err.Error())
+	assert.ErrorIs(t, err, ErrInvalidSession)
+	assert.EqualError(t, errors.Unwrap(err), "test")
 }
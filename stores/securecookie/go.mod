@@ -1,10 +1,11 @@
 module github.com/zerodha/simplesessions/stores/securecookie/v3
 
-go 1.18
+go 1.21
 
 require (
 	github.com/gorilla/securecookie v1.1.2
 	github.com/stretchr/testify v1.9.0
+	github.com/zerodha/simplesessions/v3 v3.1.0
 )
 
 require (
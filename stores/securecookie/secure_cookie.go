@@ -1,10 +1,13 @@
 package securecookie
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"sync"
 
 	"github.com/gorilla/securecookie"
+	"github.com/zerodha/simplesessions/v3"
 )
 
 const (
@@ -12,25 +15,15 @@ const (
 )
 
 var (
-	// Error codes for store errors. This should match the codes
-	// defined in the /simplesessions package exactly.
-	ErrInvalidSession = &Err{code: 1, msg: "invalid session"}
-	ErrAssertType     = &Err{code: 2, msg: "assertion failed"}
-	ErrNil            = &Err{code: 3, msg: "nil returned"}
+	// Errors returned by the store. Their codes map them to the errors in the
+	// simplesessions package. Errors with an underlying cause wrap it.
+	ErrInvalidSession = simplesessions.NewStoreError(simplesessions.CodeInvalidSession, "invalid session")
+	ErrNil            = simplesessions.NewStoreError(simplesessions.CodeNil, "nil returned")
+	ErrAssertType     = simplesessions.NewStoreError(simplesessions.CodeAssertType, "assertion failed")
 )
 
-type Err struct {
-	code int
-	msg  string
-}
-
-func (e *Err) Error() string {
-	return e.msg
-}
-
-func (e *Err) Code() int {
-	return e.code
-}
+// Err is the type of the errors returned by the store.
+type Err = simplesessions.StoreError
 
 // Store represents secure cookie session store
 type Store struct {
@@ -38,8 +31,20 @@ type Store struct {
 	tempSetMap map[string]map[string]interface{}
 	mu         sync.RWMutex
 
-	sc         *securecookie.SecureCookie
+	// Codecs for the key pairs, newest first. Cookies are encoded
+	// with the first codec and decoded with any of them.
+	codecs     []*securecookie.SecureCookie
 	cookieName string
+
+	// Logger for cookies that fail to decode. Disabled if nil.
+	log *slog.Logger
+}
+
+// KeyPair is a pair of keys used to authenticate and encrypt the cookie.
+// See `New()` for the key requirements.
+type KeyPair struct {
+	HashKey  []byte
+	BlockKey []byte
 }
 
 // New creates a new secure cookie store instance. Gorilla/securecookie is used to encode and
@@ -50,30 +55,72 @@ type Store struct {
 // If set, the length must correspond to the block size of the encryption algorithm.
 // For AES, used by default, valid lengths are 16, 24, or 32 bytes to select AES-128, AES-192, or AES-256.
 func New(secretKey []byte, blockKey []byte) *Store {
+	return NewWithKeys(KeyPair{HashKey: secretKey, BlockKey: blockKey})
+}
+
+// NewWithKeys creates a new secure cookie store instance with multiple key pairs
+// for rotating keys without invalidating existing sessions. The keys should be
+// ordered newest first. Cookies are always encoded with the first key pair and
+// can be decoded with any of them. Cookies encoded with an older key pair are
+// re-encoded with the newest one on the next write. Use `NeedsRotation()` to
+// check if a cookie is encoded with an older key pair.
+func NewWithKeys(keys ...KeyPair) *Store {
+	codecs := make([]*securecookie.SecureCookie, len(keys))
+	for i, k := range keys {
+		codecs[i] = securecookie.New(k.HashKey, k.BlockKey)
+	}
+
 	return &Store{
 		cookieName: defaultCookieName,
-		sc:         securecookie.New(secretKey, blockKey),
+		codecs:     codecs,
 		tempSetMap: make(map[string]map[string]interface{}),
 	}
 }
 
-// encode and encrypt given interface
+// encode and encrypt given interface with the newest key pair.
 func (s *Store) encode(val interface{}) (string, error) {
-	return s.sc.Encode(s.cookieName, val)
+	if len(s.codecs) == 0 {
+		return "", fmt.Errorf("no keys set")
+	}
+
+	return s.codecs[0].Encode(s.cookieName, val)
 }
 
 // decode encoded value to map
 func (s *Store) decode(cookieVal string) (map[string]interface{}, error) {
-	val := make(map[string]interface{})
-	err := s.sc.Decode(s.cookieName, cookieVal, &val)
+	val, _, err := s.decodeKey(cookieVal)
 	return val, err
 }
 
+// decodeKey decodes the encoded value to map with the key pairs in order
+// and returns the index of the key pair that decoded it.
+func (s *Store) decodeKey(cookieVal string) (map[string]interface{}, int, error) {
+	err := fmt.Errorf("no keys set")
+	for i, c := range s.codecs {
+		val := make(map[string]interface{})
+		if err = c.Decode(s.cookieName, cookieVal, &val); err == nil {
+			return val, i, nil
+		}
+	}
+
+	if s.log != nil {
+		s.log.Debug("error decoding cookie", slog.String("session_id", simplesessions.RedactID(cookieVal)), slog.Any("error", err))
+	}
+	return nil, -1, err
+}
+
 // SetCookieName sets the cookie name for securecookie
 func (s *Store) SetCookieName(cookieName string) {
 	s.cookieName = cookieName
 }
 
+// SetLogger sets the logger for structured logs of cookies that fail to decode, eg:
+// tampered cookies or ones encoded with a removed key. Cookie values are redacted
+// with simplesessions.RedactID(). nil disables logging, which is the default.
+func (s *Store) SetLogger(l *slog.Logger) {
+	s.log = l
+}
+
 // IsValid checks if the given cookie value is valid.
 func (s *Store) IsValid(cv string) bool {
 	if _, err := s.decode(cv); err != nil {
@@ -82,6 +129,14 @@ func (s *Store) IsValid(cv string) bool {
 	return true
 }
 
+// NeedsRotation checks if the given cookie value is valid but encoded with
+// an older key pair. Such cookies are re-encoded with the newest key pair
+// on the next write to the session.
+func (s *Store) NeedsRotation(cv string) bool {
+	_, i, err := s.decodeKey(cv)
+	return err == nil && i > 0
+}
+
 // Create creates a new secure cookie session with empty map.
 // Once called, Flush() should be called to retrieve the updated.
 func (s *Store) Create(id string) error {
@@ -91,12 +146,22 @@ func (s *Store) Create(id string) error {
 	return nil
 }
 
+// CreateCtx is the context aware version of Create.
+// It returns the context error if the context is done.
+func (s *Store) CreateCtx(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.Create(id)
+}
+
 // Get returns a field value from session
 func (s *Store) Get(cv, key string) (interface{}, error) {
 	// Decode cookie value
 	vals, err := s.decode(cv)
 	if err != nil {
-		return nil, ErrInvalidSession
+		return nil, ErrInvalidSession.Wrap(err)
 	}
 
 	// Get given field
@@ -108,13 +173,23 @@ func (s *Store) Get(cv, key string) (interface{}, error) {
 	return val, nil
 }
 
+// GetCtx is the context aware version of Get.
+// It returns the context error if the context is done.
+func (s *Store) GetCtx(ctx context.Context, cv, key string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.Get(cv, key)
+}
+
 // GetMulti returns values for multiple fields in session.
 // If a field is not present then nil is returned.
 func (s *Store) GetMulti(cv string, keys ...string) (map[string]interface{}, error) {
 	// Decode cookie value
 	vals, err := s.decode(cv)
 	if err != nil {
-		return nil, ErrInvalidSession
+		return nil, ErrInvalidSession.Wrap(err)
 	}
 
 	// Get all given fields
@@ -132,16 +207,36 @@ func (s *Store) GetMulti(cv string, keys ...string) (map[string]interface{}, err
 	return res, nil
 }
 
+// GetMultiCtx is the context aware version of GetMulti.
+// It returns the context error if the context is done.
+func (s *Store) GetMultiCtx(ctx context.Context, cv string, keys ...string) (map[string]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.GetMulti(cv, keys...)
+}
+
 // GetAll returns all field for given session.
 func (s *Store) GetAll(cv string) (map[string]interface{}, error) {
 	vals, err := s.decode(cv)
 	if err != nil {
-		return nil, ErrInvalidSession
+		return nil, ErrInvalidSession.Wrap(err)
 	}
 
 	return vals, nil
 }
 
+// GetAllCtx is the context aware version of GetAll.
+// It returns the context error if the context is done.
+func (s *Store) GetAllCtx(ctx context.Context, cv string) (map[string]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.GetAll(cv)
+}
+
 // Set sets a field in session but not saved untill commit is called.
 // Flush() should be called to retrieve the updated, unflushed values
 // and written to the cookie externally.
@@ -149,17 +244,27 @@ func (s *Store) Set(cv, key string, val interface{}) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Create session map if doesn't exist
-	if _, ok := s.tempSetMap[cv]; !ok {
-		s.tempSetMap[cv] = make(map[string]interface{})
+	vals, err := s.pending(cv)
+	if err != nil {
+		return err
 	}
 
 	// set value to map
-	s.tempSetMap[cv][key] = val
+	vals[key] = val
 
 	return nil
 }
 
+// SetCtx is the context aware version of Set.
+// It returns the context error if the context is done.
+func (s *Store) SetCtx(ctx context.Context, cv, key string, val interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.Set(cv, key, val)
+}
+
 // SetMulti sets given map of kv pairs to session. Flush() should be
 // called to retrieve the updated, unflushed values and written to the cookie
 // externally.
@@ -167,23 +272,32 @@ func (s *Store) SetMulti(cv string, vals map[string]interface{}) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Create session map if doesn't exist
-	if _, ok := s.tempSetMap[cv]; !ok {
-		s.tempSetMap[cv] = make(map[string]interface{})
+	pending, err := s.pending(cv)
+	if err != nil {
+		return err
 	}
 
 	for k, v := range vals {
-		s.tempSetMap[cv][k] = v
+		pending[k] = v
 	}
 
 	return nil
 }
 
+// SetMultiCtx is the context aware version of SetMulti.
+// It returns the context error if the context is done.
+func (s *Store) SetMultiCtx(ctx context.Context, cv string, vals map[string]interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.SetMulti(cv, vals)
+}
+
 // Flush flushes the 'set' buffer and returns encoded secure cookie value ready to be saved.
-// This value should be written to the cookie externally.
-// This can be used with simplessions.Session.WriteCookie.
-// val, _ := str.Flush(cookieVal)
-// sess.WriteCookie(val)
+// The buffer is keyed by the cookie value and is shared by all the callers with the same
+// cookie. Sessions don't use it. They hold their own copy of the data and encode it with
+// Encode() after every mutation and write the returned value to the cookie automatically.
 func (s *Store) Flush(cv string) (string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -199,37 +313,42 @@ func (s *Store) Flush(cv string) (string, error) {
 	return encoded, err
 }
 
+// Encode returns the encoded secure cookie value of the given session data.
+func (s *Store) Encode(data map[string]interface{}) (string, error) {
+	return s.encode(data)
+}
+
 // Delete deletes a field from session. Once called, Flush() should be
 // called to retrieve the updated, unflushed values and written to the cookie
 // externally.
 func (s *Store) Delete(cv string, keys ...string) error {
-	// Decode current cookie
-	vals, err := s.decode(cv)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	vals, err := s.pending(cv)
 	if err != nil {
-		return ErrInvalidSession
+		return err
 	}
 
 	for _, k := range keys {
-		// Delete given key in current values.
 		delete(vals, k)
 	}
 
-	// Create session map if doesn't exist.
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	if _, ok := s.tempSetMap[cv]; !ok {
-		s.tempSetMap[cv] = make(map[string]interface{})
-	}
-
-	for k, v := range vals {
-		s.tempSetMap[cv][k] = v
-	}
-
 	// After this, Flush() should be called to obtain the updated encoded
 	// values to be written to the cookie externally.
 	return nil
 }
 
+// DeleteCtx is the context aware version of Delete.
+// It returns the context error if the context is done.
+func (s *Store) DeleteCtx(ctx context.Context, cv string, keys ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.Delete(cv, keys...)
+}
+
 // Clear clears the session. Once called, Flush() should be
 // called to retrieve the updated, unflushed values and written to the cookie
 // externally.
@@ -240,19 +359,80 @@ func (s *Store) Clear(cv string) error {
 	return nil
 }
 
-// Destroy clears the session. Once called, Flush() should be
-// called to retrieve the updated, unflushed values and written to the cookie
-// externally.
-func (s *Store) Destroy(cv string) error {
+// ClearCtx is the context aware version of Clear.
+// It returns the context error if the context is done.
+func (s *Store) ClearCtx(ctx context.Context, cv string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	return s.Clear(cv)
 }
 
+// Destroy discards the unflushed values of the session. As the session
+// lives in the cookie, the cookie should be cleared externally.
+func (s *Store) Destroy(cv string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tempSetMap, cv)
+	return nil
+}
+
+// DestroyCtx is the context aware version of Destroy.
+// It returns the context error if the context is done.
+func (s *Store) DestroyCtx(ctx context.Context, cv string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.Destroy(cv)
+}
+
+// Rename moves the session values to a new ID. Once called, Flush() should be
+// called with the new ID to retrieve the encoded values and written to the cookie
+// externally.
+func (s *Store) Rename(cv, newID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Carry over any unflushed values of the old session.
+	vals, err := s.pending(cv)
+	if err != nil {
+		return err
+	}
+	delete(s.tempSetMap, cv)
+	s.tempSetMap[newID] = vals
+
+	return nil
+}
+
+// pending returns the unflushed values of the session. If there are none,
+// they're initialized with the values decoded from the cookie so that the
+// next Flush() retains them. s.mu should be locked by the caller.
+func (s *Store) pending(cv string) (map[string]interface{}, error) {
+	if vals, ok := s.tempSetMap[cv]; ok {
+		return vals, nil
+	}
+
+	vals, err := s.decode(cv)
+	if err != nil {
+		return nil, ErrInvalidSession.Wrap(err)
+	}
+	s.tempSetMap[cv] = vals
+
+	return vals, nil
+}
+
 // Int is a helper method to type assert as integer
 func (s *Store) Int(r interface{}, err error) (int, error) {
 	if err != nil {
 		return 0, err
 	}
 
+	if r == nil {
+		return 0, ErrNil
+	}
+
 	v, ok := r.(int)
 	if !ok {
 		err = ErrAssertType
@@ -267,6 +447,10 @@ func (s *Store) Int64(r interface{}, err error) (int64, error) {
 		return 0, err
 	}
 
+	if r == nil {
+		return 0, ErrNil
+	}
+
 	v, ok := r.(int64)
 	if !ok {
 		err = ErrAssertType
@@ -281,6 +465,10 @@ func (s *Store) UInt64(r interface{}, err error) (uint64, error) {
 		return 0, err
 	}
 
+	if r == nil {
+		return 0, ErrNil
+	}
+
 	v, ok := r.(uint64)
 	if !ok {
 		err = ErrAssertType
@@ -295,6 +483,10 @@ func (s *Store) Float64(r interface{}, err error) (float64, error) {
 		return 0, err
 	}
 
+	if r == nil {
+		return 0, ErrNil
+	}
+
 	v, ok := r.(float64)
 	if !ok {
 		err = ErrAssertType
@@ -309,6 +501,10 @@ func (s *Store) String(r interface{}, err error) (string, error) {
 		return "", err
 	}
 
+	if r == nil {
+		return "", ErrNil
+	}
+
 	v, ok := r.(string)
 	if !ok {
 		err = ErrAssertType
@@ -323,6 +519,10 @@ func (s *Store) Bytes(r interface{}, err error) ([]byte, error) {
 		return nil, err
 	}
 
+	if r == nil {
+		return nil, ErrNil
+	}
+
 	v, ok := r.([]byte)
 	if !ok {
 		err = ErrAssertType
@@ -337,6 +537,10 @@ func (s *Store) Bool(r interface{}, err error) (bool, error) {
 		return false, err
 	}
 
+	if r == nil {
+		return false, ErrNil
+	}
+
 	v, ok := r.(bool)
 	if !ok {
 		err = ErrAssertType
@@ -1,10 +1,18 @@
 package securecookie
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/zerodha/simplesessions/v3"
+	"github.com/zerodha/simplesessions/v3/storetest"
 )
 
 var (
@@ -15,10 +23,52 @@ var (
 func TestNew(t *testing.T) {
 	str := New(secretKey, blockKey)
 
-	assert.NotNil(t, str.sc)
+	assert.Len(t, str.codecs, 1)
 	assert.NotNil(t, str.tempSetMap)
 }
 
+func TestConformance(t *testing.T) {
+	storetest.RunConformance(t, func() simplesessions.Store {
+		return New(secretKey, blockKey)
+	})
+}
+
+func TestKeyRotation(t *testing.T) {
+	var (
+		oldKeys = KeyPair{HashKey: secretKey, BlockKey: blockKey}
+		newKeys = KeyPair{
+			HashKey:  []byte("Hx7SZr0uYd3Nwq8LbKo2VjT5mEcA9fRg"),
+			BlockKey: []byte("pQ4zW1tXn6Us8HyBk3GvJ0aLr7DcFe2M"),
+		}
+		oldStr = NewWithKeys(oldKeys)
+		str    = NewWithKeys(newKeys, oldKeys)
+	)
+
+	// Cookies encoded with the old key are decoded.
+	cv, err := oldStr.encode(map[string]interface{}{"key1": "val1"})
+	assert.NoError(t, err)
+	assert.True(t, str.IsValid(cv))
+	assert.True(t, str.NeedsRotation(cv))
+	v, err := str.Get(cv, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "val1", v)
+
+	// Writes re-encode with the newest key.
+	assert.NoError(t, str.Set(cv, "key2", "val2"))
+	cv, err = str.Flush(cv)
+	assert.NoError(t, err)
+	assert.False(t, str.NeedsRotation(cv))
+	assert.False(t, oldStr.IsValid(cv))
+
+	vals, err := str.GetAll(cv)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"key1": "val1", "key2": "val2"}, vals)
+
+	// Invalid cookies don't need rotation.
+	assert.False(t, str.NeedsRotation("invalidkey"))
+	assert.False(t, NewWithKeys().IsValid(cv))
+}
+
 func TestSetCookieName(t *testing.T) {
 	str := New(secretKey, blockKey)
 	assert.Equal(t, defaultCookieName, str.cookieName)
@@ -36,6 +86,23 @@ func TestIsValid(t *testing.T) {
 	assert.True(t, str.IsValid(encoded))
 }
 
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	str := New(secretKey, blockKey)
+	str.SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	encoded, err := str.encode(make(map[string]interface{}))
+	assert.Nil(t, err)
+	assert.True(t, str.IsValid(encoded))
+	assert.Empty(t, buf.String())
+
+	// Tampered cookies are logged with the redacted value.
+	tampered := encoded + "x"
+	assert.False(t, str.IsValid(tampered))
+	assert.Contains(t, buf.String(), "msg=\"error decoding cookie\" session_id="+tampered[:4]+"***")
+	assert.NotContains(t, buf.String(), tampered)
+}
+
 func TestCreate(t *testing.T) {
 	str := New(secretKey, blockKey)
 
@@ -210,6 +277,168 @@ func TestDestroy(t *testing.T) {
 	err := str.Destroy("xxx")
 	assert.Nil(t, err)
 	assert.Equal(t, len(str.tempSetMap["xxx"]), 0)
+
+	// Unflushed values are discarded.
+	assert.NoError(t, str.Create("xxx"))
+	assert.NoError(t, str.Destroy("xxx"))
+	assert.NotContains(t, str.tempSetMap, "xxx")
+}
+
+func TestFlushRetainsValues(t *testing.T) {
+	str := New(secretKey, blockKey)
+	cv, err := str.encode(map[string]interface{}{"key1": "val1", "key2": "val2"})
+	assert.Nil(t, err)
+
+	// Writes are applied over the values in the cookie.
+	assert.NoError(t, str.Set(cv, "key3", "val3"))
+	assert.NoError(t, str.Delete(cv, "key1"))
+	assert.NoError(t, str.SetMulti(cv, map[string]interface{}{"key4": "val4"}))
+
+	cv, err = str.Flush(cv)
+	assert.NoError(t, err)
+	vals, err := str.GetAll(cv)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"key2": "val2", "key3": "val3", "key4": "val4"}, vals)
+	assert.Empty(t, str.tempSetMap)
+
+	// Writes to invalid cookies are rejected.
+	assert.ErrorIs(t, str.Set("invalidkey", "key1", "val1"), ErrInvalidSession)
+}
+
+func TestRename(t *testing.T) {
+	str := New(secretKey, blockKey)
+	err := str.Rename("invalidkey", "newid")
+	assert.ErrorIs(t, err, ErrInvalidSession)
+
+	cv, err := str.encode(map[string]interface{}{"key1": "val1"})
+	assert.Nil(t, err)
+
+	err = str.Rename(cv, "newid")
+	assert.Nil(t, err)
+	assert.NotContains(t, str.tempSetMap, cv)
+	assert.Equal(t, "val1", str.tempSetMap["newid"]["key1"])
+}
+
+func TestRegenerate(t *testing.T) {
+	var (
+		str = New(secretKey, blockKey)
+		m   = simplesessions.New(simplesessions.Options{})
+		ck  *http.Cookie
+	)
+	m.UseStore(str)
+	m.SetCookieHooks(func(string, interface{}) (*http.Cookie, error) {
+		return ck, nil
+	}, func(c *http.Cookie, _ interface{}) error {
+		ck = c
+		return nil
+	})
+
+	sess, err := m.NewSession(nil, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, sess.Set("key1", "val1"))
+	old := ck.Value
+
+	// The cookie is rewritten with the encoded values and not the generated ID.
+	assert.NoError(t, sess.Regenerate())
+	assert.NotEqual(t, old, ck.Value)
+	assert.Equal(t, sess.ID(), ck.Value)
+	assert.True(t, str.IsValid(ck.Value))
+
+	sess, err = m.Acquire(context.Background(), nil, nil)
+	assert.NoError(t, err)
+	v, err := sess.String(sess.Get("key1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "val1", v)
+}
+
+// barrierStore blocks in Flush and Encode until all the sessions of the test have
+// written so that none of them is flushed before the others have written.
+type barrierStore struct {
+	*Store
+	wg *sync.WaitGroup
+}
+
+func (b *barrierStore) Flush(cv string) (string, error) {
+	b.wait()
+	return b.Store.Flush(cv)
+}
+
+func (b *barrierStore) Encode(data map[string]interface{}) (string, error) {
+	b.wait()
+	return b.Store.Encode(data)
+}
+
+func (b *barrierStore) wait() {
+	if b.wg != nil {
+		b.wg.Done()
+		b.wg.Wait()
+	}
+}
+
+func TestConcurrentSessions(t *testing.T) {
+	var (
+		str = &barrierStore{Store: New(secretKey, blockKey)}
+		m   = simplesessions.New(simplesessions.Options{})
+	)
+	m.UseStore(str)
+
+	// The writer of every request is the cookie it writes.
+	m.SetCookieHooks(func(_ string, r interface{}) (*http.Cookie, error) {
+		return r.(*http.Cookie), nil
+	}, func(c *http.Cookie, w interface{}) error {
+		*w.(*http.Cookie) = *c
+		return nil
+	})
+
+	var ck http.Cookie
+	sess, err := m.NewSession(nil, &ck)
+	assert.NoError(t, err)
+	assert.NoError(t, sess.Set("key", "val"))
+
+	// Concurrent requests with the same cookie don't share their writes.
+	var (
+		cks = make([]http.Cookie, 5)
+		wg  sync.WaitGroup
+	)
+	str.wg = &sync.WaitGroup{}
+	str.wg.Add(len(cks))
+	for i := range cks {
+		sess, err := m.Acquire(context.Background(), &ck, &cks[i])
+		assert.NoError(t, err)
+
+		wg.Add(1)
+		go func(k string) {
+			defer wg.Done()
+			assert.NoError(t, sess.Set(k, k))
+		}(fmt.Sprintf("key%d", i))
+	}
+	wg.Wait()
+
+	for i := range cks {
+		k := fmt.Sprintf("key%d", i)
+		vals, err := str.GetAll(cks[i].Value)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"key": "val", k: k}, vals)
+	}
+	assert.Empty(t, str.tempSetMap)
+}
+
+func TestContext(t *testing.T) {
+	str := New(secretKey, blockKey)
+	cv, err := str.encode(map[string]interface{}{"key1": "val1"})
+	assert.Nil(t, err)
+
+	v, err := str.GetCtx(context.Background(), cv, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "val1", v)
+
+	// Cancelled contexts are rejected.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = str.GetCtx(ctx, cv, "key1")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, str.SetCtx(ctx, cv, "key1", "val2"), context.Canceled)
+	assert.NotContains(t, str.tempSetMap, cv)
 }
 
 func TestFlush(t *testing.T) {
@@ -349,10 +578,14 @@ func TestBool(t *testing.T) {
 }
 
 func TestError(t *testing.T) {
-	err := Err{
-		code: 1,
-		msg:  "test",
-	}
-	assert.Equal(t, 1, err.Code())
-	assert.Equal(t, "test", err.Error())
+	assert.Equal(t, simplesessions.CodeInvalidSession, ErrInvalidSession.Code())
+	assert.Equal(t, simplesessions.CodeNil, ErrNil.Code())
+	assert.Equal(t, simplesessions.CodeAssertType, ErrAssertType.Code())
+	assert.ErrorIs(t, ErrInvalidSession, simplesessions.ErrInvalidSession)
+
+	// Causes are wrapped.
+	err := ErrInvalidSession.Wrap(errors.New("test"))
+	assert.Equal(t, "invalid session: test", err.Error())
+	assert.ErrorIs(t, err, ErrInvalidSession)
+	assert.EqualError(t, errors.Unwrap(err), "test")
 }
@@ -0,0 +1,458 @@
+// Package storetest provides a conformance test suite for simplesessions.Store
+// implementations. Stores should pass it to behave consistently with the bundled
+// stores when used with a Manager.
+//
+//	func TestConformance(t *testing.T) {
+//		storetest.RunConformance(t, func() simplesessions.Store {
+//			return mystore.New()
+//		})
+//	}
+package storetest
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerodha/simplesessions/v3"
+)
+
+var errTest = errors.New("storetest: test error")
+
+// RunConformance runs the conformance tests against the store returned by newStore.
+// newStore is called for every test. Stores backed by a shared backend, such as Redis,
+// can return the same instance as sessions are created with unique random IDs.
+//
+// Writes to stores that implement simplesessions.Flusher are flushed after every
+// write and the flushed value is used as the session ID thereafter.
+// The optional Renamer, StoreContext and UserIndexStore interfaces are tested if implemented.
+func RunConformance(t *testing.T, newStore func() simplesessions.Store) {
+	tests := []struct {
+		name string
+		fn   func(*suite)
+	}{
+		{"Create", testCreate},
+		{"InvalidSession", testInvalidSession},
+		{"Set", testSet},
+		{"SetMulti", testSetMulti},
+		{"GetMulti", testGetMulti},
+		{"Delete", testDelete},
+		{"Clear", testClear},
+		{"Destroy", testDestroy},
+		{"Rename", testRename},
+		{"Context", testContext},
+		{"Helpers", testHelpers},
+		{"UserIndex", testUserIndex},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(&suite{t: t, st: newStore()})
+		})
+	}
+}
+
+type suite struct {
+	t  *testing.T
+	st simplesessions.Store
+}
+
+// create creates a new session and returns its ID.
+func (s *suite) create() string {
+	id := newID(s.t)
+	require.NoError(s.t, s.st.Create(id))
+
+	return s.flush(id)
+}
+
+// flush flushes the writes to the session if the store is a Flusher
+// and returns the new session ID. Otherwise, the ID is returned as is.
+func (s *suite) flush(id string) string {
+	f, ok := s.st.(simplesessions.Flusher)
+	if !ok {
+		return id
+	}
+
+	id, err := f.Flush(id)
+	require.NoError(s.t, err)
+
+	return id
+}
+
+// isFlusher checks if the store holds the session data on the client.
+// Such stores can't invalidate sessions on the server.
+func (s *suite) isFlusher() bool {
+	_, ok := s.st.(simplesessions.Flusher)
+	return ok
+}
+
+// assertCode asserts that the error has the given error code.
+func (s *suite) assertCode(err error, code int, msg string) {
+	s.t.Helper()
+
+	var e interface{ Code() int }
+	if !errors.As(err, &e) {
+		s.t.Errorf("%s: expected an error with code %d, got: %v", msg, code, err)
+		return
+	}
+	assert.Equal(s.t, code, e.Code(), msg)
+}
+
+func testCreate(s *suite) {
+	id := s.create()
+
+	all, err := s.st.GetAll(id)
+	assert.NoError(s.t, err)
+	assert.Len(s.t, all, 0)
+
+	v, err := s.st.Get(id, "foo")
+	assert.NoError(s.t, err)
+	assert.Nil(s.t, v)
+}
+
+func testInvalidSession(s *suite) {
+	id := newID(s.t)
+
+	_, err := s.st.Get(id, "foo")
+	s.assertCode(err, simplesessions.CodeInvalidSession, "Get")
+
+	_, err = s.st.GetMulti(id, "foo", "bar")
+	s.assertCode(err, simplesessions.CodeInvalidSession, "GetMulti")
+
+	_, err = s.st.GetAll(id)
+	s.assertCode(err, simplesessions.CodeInvalidSession, "GetAll")
+}
+
+func testSet(s *suite) {
+	id := s.create()
+
+	require.NoError(s.t, s.st.Set(id, "str", "bar"))
+	id = s.flush(id)
+	require.NoError(s.t, s.st.Set(id, "int", 10))
+	id = s.flush(id)
+
+	str, err := s.st.String(s.st.Get(id, "str"))
+	assert.NoError(s.t, err)
+	assert.Equal(s.t, "bar", str)
+
+	n, err := s.st.Int(s.st.Get(id, "int"))
+	assert.NoError(s.t, err)
+	assert.Equal(s.t, 10, n)
+
+	// Overwrite a value.
+	require.NoError(s.t, s.st.Set(id, "str", "baz"))
+	id = s.flush(id)
+
+	str, err = s.st.String(s.st.Get(id, "str"))
+	assert.NoError(s.t, err)
+	assert.Equal(s.t, "baz", str)
+}
+
+func testSetMulti(s *suite) {
+	id := s.create()
+
+	require.NoError(s.t, s.st.SetMulti(id, map[string]interface{}{
+		"str":   "bar",
+		"int":   10,
+		"float": 1.5,
+		"bool":  true,
+	}))
+	id = s.flush(id)
+
+	all, err := s.st.GetAll(id)
+	require.NoError(s.t, err)
+	assert.Len(s.t, all, 4)
+
+	str, err := s.st.String(all["str"], nil)
+	assert.NoError(s.t, err)
+	assert.Equal(s.t, "bar", str)
+
+	n, err := s.st.Int(all["int"], nil)
+	assert.NoError(s.t, err)
+	assert.Equal(s.t, 10, n)
+
+	f, err := s.st.Float64(all["float"], nil)
+	assert.NoError(s.t, err)
+	assert.Equal(s.t, 1.5, f)
+
+	b, err := s.st.Bool(all["bool"], nil)
+	assert.NoError(s.t, err)
+	assert.True(s.t, b)
+}
+
+func testGetMulti(s *suite) {
+	id := s.create()
+
+	require.NoError(s.t, s.st.SetMulti(id, map[string]interface{}{
+		"foo": "bar",
+		"baz": "qux",
+	}))
+	id = s.flush(id)
+
+	// Missing keys are returned as nil.
+	vals, err := s.st.GetMulti(id, "foo", "missing")
+	require.NoError(s.t, err)
+	assert.Len(s.t, vals, 2)
+	assert.Contains(s.t, vals, "missing")
+	assert.Nil(s.t, vals["missing"])
+
+	str, err := s.st.String(vals["foo"], nil)
+	assert.NoError(s.t, err)
+	assert.Equal(s.t, "bar", str)
+}
+
+func testDelete(s *suite) {
+	id := s.create()
+
+	require.NoError(s.t, s.st.SetMulti(id, map[string]interface{}{
+		"foo": "bar",
+		"baz": "qux",
+		"one": "two",
+	}))
+	id = s.flush(id)
+
+	require.NoError(s.t, s.st.Delete(id, "foo", "baz"))
+	id = s.flush(id)
+
+	all, err := s.st.GetAll(id)
+	require.NoError(s.t, err)
+	assert.Len(s.t, all, 1)
+	assert.Contains(s.t, all, "one")
+
+	v, err := s.st.Get(id, "foo")
+	assert.NoError(s.t, err)
+	assert.Nil(s.t, v)
+}
+
+func testClear(s *suite) {
+	id := s.create()
+
+	require.NoError(s.t, s.st.Set(id, "foo", "bar"))
+	id = s.flush(id)
+
+	require.NoError(s.t, s.st.Clear(id))
+	id = s.flush(id)
+
+	all, err := s.st.GetAll(id)
+	require.NoError(s.t, err)
+	assert.Len(s.t, all, 0)
+
+	// The session is still valid.
+	require.NoError(s.t, s.st.Set(id, "foo", "baz"))
+	id = s.flush(id)
+
+	str, err := s.st.String(s.st.Get(id, "foo"))
+	assert.NoError(s.t, err)
+	assert.Equal(s.t, "baz", str)
+}
+
+func testDestroy(s *suite) {
+	id := s.create()
+
+	require.NoError(s.t, s.st.Set(id, "foo", "bar"))
+	id = s.flush(id)
+
+	require.NoError(s.t, s.st.Destroy(id))
+
+	// Client-side sessions are destroyed by clearing the cookie.
+	if s.isFlusher() {
+		return
+	}
+
+	_, err := s.st.Get(id, "foo")
+	s.assertCode(err, simplesessions.CodeInvalidSession, "Get after Destroy")
+
+	_, err = s.st.GetAll(id)
+	s.assertCode(err, simplesessions.CodeInvalidSession, "GetAll after Destroy")
+
+	s.assertCode(s.st.Destroy(id), simplesessions.CodeInvalidSession, "Destroy after Destroy")
+}
+
+func testRename(s *suite) {
+	r, ok := s.st.(simplesessions.Renamer)
+	if !ok {
+		s.t.Skip("store doesn't implement Renamer")
+	}
+
+	err := r.Rename(newID(s.t), newID(s.t))
+	s.assertCode(err, simplesessions.CodeInvalidSession, "Rename of an invalid session")
+
+	id := s.create()
+	require.NoError(s.t, s.st.Set(id, "foo", "bar"))
+	id = s.flush(id)
+
+	to := newID(s.t)
+	require.NoError(s.t, r.Rename(id, to))
+	to = s.flush(to)
+
+	str, err := s.st.String(s.st.Get(to, "foo"))
+	assert.NoError(s.t, err)
+	assert.Equal(s.t, "bar", str)
+
+	if !s.isFlusher() {
+		_, err = s.st.GetAll(id)
+		s.assertCode(err, simplesessions.CodeInvalidSession, "GetAll of the renamed session")
+	}
+}
+
+func testContext(s *suite) {
+	c, ok := s.st.(simplesessions.StoreContext)
+	if !ok {
+		s.t.Skip("store doesn't implement StoreContext")
+	}
+
+	id := newID(s.t)
+	require.NoError(s.t, c.CreateCtx(context.Background(), id))
+	id = s.flush(id)
+
+	require.NoError(s.t, c.SetCtx(context.Background(), id, "foo", "bar"))
+	id = s.flush(id)
+
+	str, err := s.st.String(c.GetCtx(context.Background(), id, "foo"))
+	assert.NoError(s.t, err)
+	assert.Equal(s.t, "bar", str)
+
+	// Cancelled contexts are rejected.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = c.GetCtx(ctx, id, "foo")
+	assert.ErrorIs(s.t, err, context.Canceled, "GetCtx")
+
+	_, err = c.GetAllCtx(ctx, id)
+	assert.ErrorIs(s.t, err, context.Canceled, "GetAllCtx")
+
+	assert.ErrorIs(s.t, c.SetCtx(ctx, id, "foo", "baz"), context.Canceled, "SetCtx")
+}
+
+func testHelpers(s *suite) {
+	helpers := map[string]func(interface{}, error) (interface{}, error){
+		"Int": func(v interface{}, err error) (interface{}, error) {
+			return s.st.Int(v, err)
+		},
+		"Int64": func(v interface{}, err error) (interface{}, error) {
+			return s.st.Int64(v, err)
+		},
+		"UInt64": func(v interface{}, err error) (interface{}, error) {
+			return s.st.UInt64(v, err)
+		},
+		"Float64": func(v interface{}, err error) (interface{}, error) {
+			return s.st.Float64(v, err)
+		},
+		"String": func(v interface{}, err error) (interface{}, error) {
+			return s.st.String(v, err)
+		},
+		"Bytes": func(v interface{}, err error) (interface{}, error) {
+			return s.st.Bytes(v, err)
+		},
+		"Bool": func(v interface{}, err error) (interface{}, error) {
+			return s.st.Bool(v, err)
+		},
+	}
+
+	for name, fn := range helpers {
+		// Errors are returned as is.
+		_, err := fn("foo", errTest)
+		assert.ErrorIs(s.t, err, errTest, name)
+
+		_, err = fn(nil, nil)
+		s.assertCode(err, simplesessions.CodeNil, name+" with nil")
+
+		_, err = fn(struct{}{}, nil)
+		s.assertCode(err, simplesessions.CodeAssertType, name+" with an invalid type")
+	}
+}
+
+func testUserIndex(s *suite) {
+	u, ok := s.st.(simplesessions.UserIndexStore)
+	if !ok {
+		s.t.Skip("store doesn't implement UserIndexStore")
+	}
+
+	var (
+		user  = newID(s.t)
+		other = newID(s.t)
+	)
+
+	err := u.SetOwner(newID(s.t), user)
+	s.assertCode(err, simplesessions.CodeInvalidSession, "SetOwner of an invalid session")
+
+	ids, err := u.UserSessions(user)
+	require.NoError(s.t, err)
+	assert.Empty(s.t, ids)
+
+	id1, id2, id3 := s.create(), s.create(), s.create()
+	require.NoError(s.t, u.SetOwner(id1, user))
+	require.NoError(s.t, u.SetOwner(id2, user))
+	require.NoError(s.t, u.SetOwner(id3, other))
+
+	ids, err = u.UserSessions(user)
+	require.NoError(s.t, err)
+	assert.ElementsMatch(s.t, []string{id1, id2}, ids)
+
+	// Cleared sessions retain the owner.
+	require.NoError(s.t, s.st.Clear(id1))
+	ids, err = u.UserSessions(user)
+	require.NoError(s.t, err)
+	assert.ElementsMatch(s.t, []string{id1, id2}, ids)
+
+	// Destroyed sessions are removed from the index.
+	require.NoError(s.t, s.st.Destroy(id2))
+	ids, err = u.UserSessions(user)
+	require.NoError(s.t, err)
+	assert.Equal(s.t, []string{id1}, ids)
+
+	// Renamed sessions are indexed by the new ID.
+	if r, ok := s.st.(simplesessions.Renamer); ok {
+		to := newID(s.t)
+		require.NoError(s.t, r.Rename(id1, to))
+		id1 = to
+
+		ids, err = u.UserSessions(user)
+		require.NoError(s.t, err)
+		assert.Equal(s.t, []string{id1}, ids)
+	}
+
+	// Changing and removing the owner. Sessions are ordered by when they were
+	// assigned to the owner. Wait so that stores with coarse clocks order them.
+	time.Sleep(time.Millisecond * 5)
+	require.NoError(s.t, u.SetOwner(id1, other))
+	ids, err = u.UserSessions(other)
+	require.NoError(s.t, err)
+	assert.Equal(s.t, []string{id3, id1}, ids)
+
+	// Setting the same owner again doesn't change the order.
+	time.Sleep(time.Millisecond * 5)
+	require.NoError(s.t, u.SetOwner(id3, other))
+	ids, err = u.UserSessions(other)
+	require.NoError(s.t, err)
+	assert.Equal(s.t, []string{id3, id1}, ids)
+
+	ids, err = u.UserSessions(user)
+	require.NoError(s.t, err)
+	assert.Empty(s.t, ids)
+
+	require.NoError(s.t, u.SetOwner(id3, ""))
+	ids, err = u.UserSessions(other)
+	require.NoError(s.t, err)
+	assert.Equal(s.t, []string{id1}, ids)
+}
+
+// newID returns a random session ID.
+func newID(t *testing.T) string {
+	const dict = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	require.NoError(t, err)
+
+	for k, v := range b {
+		b[k] = dict[v%byte(len(dict))]
+	}
+
+	return string(b)
+}
@@ -0,0 +1,257 @@
+package storetest
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/zerodha/simplesessions/v3"
+)
+
+var (
+	errInvalidSession = simplesessions.NewStoreError(simplesessions.CodeInvalidSession, "invalid session")
+	errNil            = simplesessions.NewStoreError(simplesessions.CodeNil, "nil returned")
+	errAssertType     = simplesessions.NewStoreError(simplesessions.CodeAssertType, "assertion failed")
+)
+
+// mapStore is a minimal map based store that conforms to the suite.
+type mapStore struct {
+	mu       sync.Mutex
+	sessions map[string]map[string]interface{}
+}
+
+func (s *mapStore) Create(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[id] = map[string]interface{}{}
+	return nil
+}
+
+func (s *mapStore) Get(id, key string) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil, errInvalidSession
+	}
+	return sess[key], nil
+}
+
+func (s *mapStore) GetMulti(id string, keys ...string) (map[string]interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil, errInvalidSession
+	}
+	out := make(map[string]interface{})
+	for _, k := range keys {
+		out[k] = sess[k]
+	}
+	return out, nil
+}
+
+func (s *mapStore) GetAll(id string) (map[string]interface{}, error) {
+	return s.GetMulti(id, s.keys(id)...)
+}
+
+func (s *mapStore) Set(id, key string, val interface{}) error {
+	return s.SetMulti(id, map[string]interface{}{key: val})
+}
+
+func (s *mapStore) SetMulti(id string, data map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return errInvalidSession
+	}
+	for k, v := range data {
+		sess[k] = v
+	}
+	return nil
+}
+
+func (s *mapStore) Delete(id string, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return errInvalidSession
+	}
+	for _, k := range keys {
+		delete(sess, k)
+	}
+	return nil
+}
+
+func (s *mapStore) Clear(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[id]; !ok {
+		return errInvalidSession
+	}
+	s.sessions[id] = map[string]interface{}{}
+	return nil
+}
+
+func (s *mapStore) Destroy(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[id]; !ok {
+		return errInvalidSession
+	}
+	delete(s.sessions, id)
+	return nil
+}
+
+func (s *mapStore) keys(id string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []string
+	for k := range s.sessions[id] {
+		out = append(out, k)
+	}
+	return out
+}
+
+func assertAs[T any](r interface{}, err error) (T, error) {
+	var v T
+	if err != nil {
+		return v, err
+	}
+	if r == nil {
+		return v, errNil
+	}
+	v, ok := r.(T)
+	if !ok {
+		return v, errAssertType
+	}
+	return v, nil
+}
+
+func (s *mapStore) Int(r interface{}, err error) (int, error) {
+	return assertAs[int](r, err)
+}
+
+func (s *mapStore) Int64(r interface{}, err error) (int64, error) {
+	return assertAs[int64](r, err)
+}
+
+func (s *mapStore) UInt64(r interface{}, err error) (uint64, error) {
+	return assertAs[uint64](r, err)
+}
+
+func (s *mapStore) Float64(r interface{}, err error) (float64, error) {
+	return assertAs[float64](r, err)
+}
+
+func (s *mapStore) String(r interface{}, err error) (string, error) {
+	return assertAs[string](r, err)
+}
+
+func (s *mapStore) Bytes(r interface{}, err error) ([]byte, error) {
+	return assertAs[[]byte](r, err)
+}
+
+func (s *mapStore) Bool(r interface{}, err error) (bool, error) {
+	return assertAs[bool](r, err)
+}
+
+// ctxMapStore additionally implements simplesessions.StoreContext.
+type ctxMapStore struct {
+	*mapStore
+}
+
+func (s ctxMapStore) CreateCtx(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Create(id)
+}
+
+func (s ctxMapStore) GetCtx(ctx context.Context, id, key string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.Get(id, key)
+}
+
+func (s ctxMapStore) GetMultiCtx(ctx context.Context, id string, keys ...string) (map[string]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.GetMulti(id, keys...)
+}
+
+func (s ctxMapStore) GetAllCtx(ctx context.Context, id string) (map[string]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.GetAll(id)
+}
+
+func (s ctxMapStore) SetCtx(ctx context.Context, id, key string, val interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Set(id, key, val)
+}
+
+func (s ctxMapStore) SetMultiCtx(ctx context.Context, id string, data map[string]interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.SetMulti(id, data)
+}
+
+func (s ctxMapStore) DeleteCtx(ctx context.Context, id string, keys ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Delete(id, keys...)
+}
+
+func (s ctxMapStore) ClearCtx(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Clear(id)
+}
+
+func (s ctxMapStore) DestroyCtx(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Destroy(id)
+}
+
+func (s ctxMapStore) Rename(id, newID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return errInvalidSession
+	}
+	delete(s.sessions, id)
+	s.sessions[newID] = sess
+	return nil
+}
+
+func newMapStore() *mapStore {
+	return &mapStore{sessions: map[string]map[string]interface{}{}}
+}
+
+func TestRunConformance(t *testing.T) {
+	t.Run("Store", func(t *testing.T) {
+		RunConformance(t, func() simplesessions.Store {
+			return newMapStore()
+		})
+	})
+
+	t.Run("StoreContext", func(t *testing.T) {
+		RunConformance(t, func() simplesessions.Store {
+			return ctxMapStore{newMapStore()}
+		})
+	})
+}
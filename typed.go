@@ -0,0 +1,334 @@
+package simplesessions
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// ValueCodec encodes and decodes session values of types that the stores don't
+// support natively, eg: structs, maps and slices. It's used by `GetAs()` and
+// `SetAs()`. Set it with `Options.Codec`. Defaults to JSONValueCodec.
+type ValueCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(b []byte, v interface{}) error
+}
+
+// JSONValueCodec encodes values as JSON.
+var JSONValueCodec ValueCodec = jsonCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(b []byte, v interface{}) error {
+	return json.Unmarshal(b, v)
+}
+
+// Basic types that the primitive kinds are stored as.
+var basicTypes = map[reflect.Kind]reflect.Type{
+	reflect.Bool:    reflect.TypeOf(false),
+	reflect.Int:     reflect.TypeOf(int(0)),
+	reflect.Int8:    reflect.TypeOf(int8(0)),
+	reflect.Int16:   reflect.TypeOf(int16(0)),
+	reflect.Int32:   reflect.TypeOf(int32(0)),
+	reflect.Int64:   reflect.TypeOf(int64(0)),
+	reflect.Uint:    reflect.TypeOf(uint(0)),
+	reflect.Uint8:   reflect.TypeOf(uint8(0)),
+	reflect.Uint16:  reflect.TypeOf(uint16(0)),
+	reflect.Uint32:  reflect.TypeOf(uint32(0)),
+	reflect.Uint64:  reflect.TypeOf(uint64(0)),
+	reflect.Float32: reflect.TypeOf(float32(0)),
+	reflect.Float64: reflect.TypeOf(float64(0)),
+	reflect.String:  reflect.TypeOf(""),
+}
+
+// GetAs gets the value of the given key from the session and converts it to T.
+// Numbers are converted between numeric types as long as they fit, eg: the float64
+// values from the Postgres store to int, and are parsed from strings, eg: the values
+// from the Redis store. Values of other types, eg: structs, are decoded with the codec
+// (see `Options.Codec`). ErrNil is returned if the key doesn't exist and ErrAssertType
+// if the value can't be converted to T.
+//
+//	n, err := simplesessions.GetAs[int](sess, "count")
+//	u, err := simplesessions.GetAs[User](sess, "user")
+func GetAs[T any](s *Session, key string) (T, error) {
+	var out T
+
+	v, err := s.Get(key)
+	if err != nil {
+		return out, err
+	}
+	if err := convertValue(reflect.ValueOf(&out).Elem(), v, s.manager.opts.Codec); err != nil {
+		return out, err
+	}
+
+	return out, nil
+}
+
+// SetAs sets the value of the given key in the session. Values of primitive types,
+// including named types such as `type Role string`, are set as their basic types
+// and the rest, eg: structs, are encoded with the codec (see `Options.Codec`) and
+// set as strings. Use `GetAs()` to read them back.
+func SetAs[T any](s *Session, key string, val T) error {
+	v, err := encodeValue(reflect.ValueOf(&val).Elem(), s.manager.opts.Codec)
+	if err != nil {
+		return err
+	}
+
+	return s.Set(key, v)
+}
+
+// encodeValue returns the value to be set in the store. Primitives are returned
+// as their basic types and the rest are encoded with the codec.
+func encodeValue(rv reflect.Value, c ValueCodec) (interface{}, error) {
+	if t, ok := basicTypes[rv.Kind()]; ok {
+		return rv.Convert(t).Interface(), nil
+	}
+	if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+		return rv.Bytes(), nil
+	}
+
+	b, err := c.Marshal(rv.Interface())
+	if err != nil {
+		return nil, err
+	}
+
+	return string(b), nil
+}
+
+// convertValue converts the value from the store to the type of dst and sets it.
+func convertValue(dst reflect.Value, v interface{}, c ValueCodec) error {
+	if v == nil {
+		return ErrNil
+	}
+
+	// Values that are already of the type, eg: from the in-memory store.
+	rv := reflect.ValueOf(v)
+	if rv.Type().AssignableTo(dst.Type()) {
+		dst.Set(rv)
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := toInt64(v)
+		if !ok || dst.OverflowInt(n) {
+			return errAssert(v, dst)
+		}
+		dst.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := toUint64(v)
+		if !ok || dst.OverflowUint(n) {
+			return errAssert(v, dst)
+		}
+		dst.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		n, ok := toFloat64(v)
+		if !ok || dst.OverflowFloat(n) {
+			return errAssert(v, dst)
+		}
+		dst.SetFloat(n)
+
+	case reflect.Bool:
+		b, ok := toBool(v)
+		if !ok {
+			return errAssert(v, dst)
+		}
+		dst.SetBool(b)
+
+	case reflect.String:
+		switch v := v.(type) {
+		case string:
+			dst.SetString(v)
+		case []byte:
+			dst.SetString(string(v))
+		default:
+			return errAssert(v, dst)
+		}
+
+	default:
+		if dst.Kind() == reflect.Slice && dst.Type().Elem().Kind() == reflect.Uint8 {
+			switch v := v.(type) {
+			case string:
+				dst.SetBytes([]byte(v))
+				return nil
+			case []byte:
+				dst.SetBytes(append([]byte(nil), v...))
+				return nil
+			}
+		}
+
+		// Decode encoded values with the codec. Values that the store has decoded
+		// itself, eg: JSON objects from the Postgres store, are re-encoded first.
+		var b []byte
+		switch v := v.(type) {
+		case string:
+			b = []byte(v)
+		case []byte:
+			b = v
+		default:
+			enc, err := c.Marshal(v)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrAssertType, err)
+			}
+			b = enc
+		}
+
+		ptr := reflect.New(dst.Type())
+		if err := c.Unmarshal(b, ptr.Interface()); err != nil {
+			return fmt.Errorf("%w: %v", ErrAssertType, err)
+		}
+		dst.Set(ptr.Elem())
+	}
+
+	return nil
+}
+
+func errAssert(v interface{}, dst reflect.Value) error {
+	return fmt.Errorf("%w: %T to %s", ErrAssertType, v, dst.Type())
+}
+
+// toInt64 converts numbers that fit in int64 and numeric strings to int64.
+// Floats are converted only if they don't have a fractional part.
+func toInt64(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint, uint8, uint16, uint32, uint64:
+		n, _ := toUint64(v)
+		if n > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(n), true
+	case float32:
+		return floatToInt64(float64(v))
+	case float64:
+		return floatToInt64(v)
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		return n, err == nil
+	case []byte:
+		n, err := strconv.ParseInt(string(v), 10, 64)
+		return n, err == nil
+	case json.Number:
+		n, err := v.Int64()
+		return n, err == nil
+	}
+
+	return 0, false
+}
+
+func floatToInt64(f float64) (int64, bool) {
+	if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+// toUint64 converts non-negative numbers and numeric strings to uint64.
+func toUint64(v interface{}) (uint64, bool) {
+	switch v := v.(type) {
+	case uint:
+		return uint64(v), true
+	case uint8:
+		return uint64(v), true
+	case uint16:
+		return uint64(v), true
+	case uint32:
+		return uint64(v), true
+	case uint64:
+		return v, true
+	case float32:
+		return floatToUint64(float64(v))
+	case float64:
+		return floatToUint64(v)
+	case string:
+		n, err := strconv.ParseUint(v, 10, 64)
+		return n, err == nil
+	case []byte:
+		n, err := strconv.ParseUint(string(v), 10, 64)
+		return n, err == nil
+	case json.Number:
+		n, err := strconv.ParseUint(v.String(), 10, 64)
+		return n, err == nil
+	}
+
+	n, ok := toInt64(v)
+	if !ok || n < 0 {
+		return 0, false
+	}
+	return uint64(n), true
+}
+
+func floatToUint64(f float64) (uint64, bool) {
+	if f != math.Trunc(f) || f < 0 || f >= math.MaxUint64 {
+		return 0, false
+	}
+	return uint64(f), true
+}
+
+// toFloat64 converts numbers and numeric strings to float64.
+func toFloat64(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		return n, err == nil
+	case []byte:
+		n, err := strconv.ParseFloat(string(v), 64)
+		return n, err == nil
+	case json.Number:
+		n, err := v.Float64()
+		return n, err == nil
+	}
+
+	if n, ok := toInt64(v); ok {
+		return float64(n), true
+	}
+	if n, ok := toUint64(v); ok {
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// toBool converts bools, boolean strings, eg: "true" and "1", and integers to bool.
+func toBool(v interface{}) (bool, bool) {
+	switch v := v.(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(v)
+		return b, err == nil
+	case []byte:
+		b, err := strconv.ParseBool(string(v))
+		return b, err == nil
+	case float32, float64:
+		return false, false
+	}
+
+	if n, ok := toInt64(v); ok {
+		return n != 0, true
+	}
+	if n, ok := toUint64(v); ok {
+		return n != 0, true
+	}
+	return false, false
+}
@@ -0,0 +1,159 @@
+package simplesessions
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testUser struct {
+	Name  string   `json:"name"`
+	Age   int      `json:"age"`
+	Roles []string `json:"roles"`
+}
+
+type testRole string
+
+func TestSetAs(t *testing.T) {
+	str := newMockStore()
+	mgr := newMockManager(str)
+	sess, err := mgr.NewSession(nil, nil)
+	assert.NoError(t, err)
+
+	// Primitives are set as their basic types.
+	assert.NoError(t, SetAs(sess, "int", 10))
+	assert.NoError(t, SetAs(sess, "role", testRole("admin")))
+	assert.NoError(t, SetAs(sess, "bytes", []byte("abc")))
+	assert.Equal(t, 10, str.data["int"])
+	assert.Equal(t, "admin", str.data["role"])
+	assert.Equal(t, []byte("abc"), str.data["bytes"])
+
+	// Composite types are encoded with the codec.
+	u := testUser{Name: "foo", Age: 30, Roles: []string{"admin"}}
+	assert.NoError(t, SetAs(sess, "user", u))
+	assert.Equal(t, `{"name":"foo","age":30,"roles":["admin"]}`, str.data["user"])
+
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.NoError(t, SetAs(sess, "time", ts))
+
+	// And read back.
+	n, err := GetAs[int](sess, "int")
+	assert.NoError(t, err)
+	assert.Equal(t, 10, n)
+
+	r, err := GetAs[testRole](sess, "role")
+	assert.NoError(t, err)
+	assert.Equal(t, testRole("admin"), r)
+
+	b, err := GetAs[[]byte](sess, "bytes")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("abc"), b)
+
+	out, err := GetAs[testUser](sess, "user")
+	assert.NoError(t, err)
+	assert.Equal(t, u, out)
+
+	ptr, err := GetAs[*testUser](sess, "user")
+	assert.NoError(t, err)
+	assert.Equal(t, &u, ptr)
+
+	tm, err := GetAs[time.Time](sess, "time")
+	assert.NoError(t, err)
+	assert.True(t, ts.Equal(tm))
+}
+
+func TestGetAs(t *testing.T) {
+	str := newMockStore()
+	mgr := newMockManager(str)
+	sess, err := mgr.NewSession(nil, nil)
+	assert.NoError(t, err)
+
+	str.data = map[string]interface{}{
+		// Postgres.
+		"float":     float64(10),
+		"fraction":  1.5,
+		"negative":  float64(-1),
+		"jsonbool":  true,
+		"jsonobj":   map[string]interface{}{"name": "foo", "age": float64(30)},
+		"jsonnum":   json.Number("42"),
+		"big":       300,
+		"largeuint": uint64(1 << 63),
+
+		// Redis.
+		"str":      "10",
+		"strfloat": "1.5",
+		"strbool":  "1",
+		"bytes":    []byte("20"),
+		"invalid":  "abc",
+	}
+
+	n, err := GetAs[int](sess, "float")
+	assert.NoError(t, err)
+	assert.Equal(t, 10, n)
+
+	n, err = GetAs[int](sess, "str")
+	assert.NoError(t, err)
+	assert.Equal(t, 10, n)
+
+	n64, err := GetAs[int64](sess, "bytes")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(20), n64)
+
+	n64, err = GetAs[int64](sess, "jsonnum")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), n64)
+
+	u, err := GetAs[uint](sess, "str")
+	assert.NoError(t, err)
+	assert.Equal(t, uint(10), u)
+
+	f, err := GetAs[float32](sess, "strfloat")
+	assert.NoError(t, err)
+	assert.Equal(t, float32(1.5), f)
+
+	f64, err := GetAs[float64](sess, "big")
+	assert.NoError(t, err)
+	assert.Equal(t, float64(300), f64)
+
+	ok, err := GetAs[bool](sess, "strbool")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = GetAs[bool](sess, "jsonbool")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	s, err := GetAs[string](sess, "bytes")
+	assert.NoError(t, err)
+	assert.Equal(t, "20", s)
+
+	usr, err := GetAs[testUser](sess, "jsonobj")
+	assert.NoError(t, err)
+	assert.Equal(t, testUser{Name: "foo", Age: 30}, usr)
+
+	// Values that can't be converted.
+	for _, fn := range []func() error{
+		func() error { _, err := GetAs[int](sess, "fraction"); return err },
+		func() error { _, err := GetAs[int](sess, "invalid"); return err },
+		func() error { _, err := GetAs[int8](sess, "big"); return err },
+		func() error { _, err := GetAs[int64](sess, "largeuint"); return err },
+		func() error { _, err := GetAs[uint](sess, "negative"); return err },
+		func() error { _, err := GetAs[bool](sess, "fraction"); return err },
+		func() error { _, err := GetAs[string](sess, "float"); return err },
+		func() error { _, err := GetAs[testUser](sess, "invalid"); return err },
+	} {
+		assert.ErrorIs(t, fn(), ErrAssertType)
+	}
+
+	// Missing keys.
+	_, err = GetAs[int](sess, "missing")
+	assert.ErrorIs(t, err, ErrNil)
+
+	// Store errors.
+	str.err = errors.New("store error")
+	_, err = GetAs[int](sess, "float")
+	assert.ErrorIs(t, err, str.err)
+}